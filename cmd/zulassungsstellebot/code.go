@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/config"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/mailcode"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/watcher"
+)
+
+// mailbox returns the IMAP code source, nil if IMAP_ADDR is not set.
+func mailbox(cfg config.Config, logger *slog.Logger) (watcher.CodeSource, error) {
+	if cfg.IMAPAddr == "" {
+		return nil, nil
+	}
+	m := &mailcode.IMAP{
+		Addr:     cfg.IMAPAddr,
+		User:     cfg.IMAPUser,
+		Password: cfg.IMAPPassword,
+		Mailbox:  cfg.IMAPMailbox,
+		From:     cfg.IMAPFrom,
+		Logger:   logger,
+	}
+	if cfg.CodePattern != "" {
+		re, err := regexp.Compile(cfg.CodePattern)
+		if err != nil {
+			return nil, fmt.Errorf("CODE_PATTERN: %w", err)
+		}
+		m.Pattern = re
+	}
+	return m, nil
+}
+
+// stdinCode asks for the verification code on the terminal when the
+// dashboard is off.
+type stdinCode struct{}
+
+func (stdinCode) Code(ctx context.Context, r watcher.CodeRequest) (string, error) {
+	fmt.Printf("📧 Bestätigungscode aus der E-Mail an %s eingeben (bis %s): ", r.Email, r.Deadline.Format("15:04"))
+	select {
+	case line, ok := <-stdinLines():
+		if !ok {
+			return "", io.EOF
+		}
+		return line, nil
+	case <-ctx.Done():
+		fmt.Println()
+		return "", ctx.Err()
+	}
+}
+
+// stdinLines reads stdin in one goroutine, so a prompt that was answered
+// from the mailbox does not leave a reader behind.
+var stdinLines = sync.OnceValue(func() <-chan string {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		sc := bufio.NewScanner(os.Stdin)
+		for sc.Scan() {
+			ch <- sc.Text()
+		}
+	}()
+	return ch
+})
+
+func codeTimeout(cfg config.Config) time.Duration {
+	return time.Duration(cfg.CodeTimeoutSec) * time.Second
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/config"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/control"
+)
+
+const defaultStatePath = "zulassungsstellebot-state.json"
+
+// runForget purges personal data from the state file. Stop the daemon first,
+// it would write its own copy back.
+func runForget(cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("forget", flag.ContinueOnError)
+	path := fs.String("file", cfg.StatePath, "state file of serve/daemon (STATE_PATH)")
+	id := fs.String("id", "", "remove only this watch, including its record")
+	all := fs.Bool("all", false, "delete the whole state file, running watches included")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *path == "" {
+		*path = defaultStatePath
+	}
+
+	if *all {
+		for _, p := range []string{*path, *path + ".tmp"} {
+			if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+		if cfg.ProfileDir != "" {
+			dirs, _ := filepath.Glob(filepath.Join(cfg.ProfileDir, "watch-*"))
+			for _, d := range dirs {
+				if err := os.RemoveAll(d); err != nil {
+					return err
+				}
+			}
+		}
+		fmt.Println("🧹 Statusdatei gelöscht:", *path)
+		return nil
+	}
+
+	// No key needed: encrypted data is dropped without being opened.
+	st, err := control.LoadState(*path, nil)
+	if err != nil {
+		return err
+	}
+	if *id != "" {
+		if err := st.Delete(*id); err != nil {
+			return err
+		}
+		if err := removeProfile(cfg.ProfileDir, *id); err != nil {
+			return err
+		}
+		fmt.Printf("🧹 Suche %s entfernt\n", *id)
+		return nil
+	}
+
+	n := 0
+	for _, e := range st.Entries() {
+		if e.Result == control.ResultRunning {
+			continue
+		}
+		if err := st.Update(e.ID, (*control.StateEntry).Forget); err != nil {
+			return err
+		}
+		if err := removeProfile(cfg.ProfileDir, e.ID); err != nil {
+			return err
+		}
+		n++
+	}
+	fmt.Printf("🧹 Persönliche Daten aus %d beendeten Suchen entfernt\n", n)
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import (
+	"context"
+	"log/slog"
+)
+
+func toggleLevelOnSignal(ctx context.Context, lv *slog.LevelVar, base slog.Level) {}
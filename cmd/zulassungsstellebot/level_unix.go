@@ -0,0 +1,30 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/logging"
+)
+
+// toggleLevelOnSignal switches between debug and the configured level on SIGUSR1.
+func toggleLevelOnSignal(ctx context.Context, lv *slog.LevelVar, base slog.Level) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1)
+	go func() {
+		defer signal.Stop(ch)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ch:
+				slog.Info("log level changed", "level", logging.Toggle(lv, base))
+			}
+		}
+	}()
+}
@@ -3,13 +3,25 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/mlentzler/ZulassungsstelleBot/internal/browser"
 	"github.com/mlentzler/ZulassungsstelleBot/internal/config"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/domain"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/health"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/history"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/logging"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/metrics"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/picker"
 	"github.com/mlentzler/ZulassungsstelleBot/internal/tui"
 
 	drvcdp "github.com/mlentzler/ZulassungsstelleBot/internal/browser/chromedp"
@@ -17,44 +29,241 @@ import (
 )
 
 func main() {
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	cmd := ""
+	if len(os.Args) > 1 {
+		cmd = os.Args[1]
+	}
+	switch cmd {
+	case "report":
+		if err := runReport(cfg, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "forget":
+		if err := runForget(cfg, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "replay":
+		if err := runReplay(cfg, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "daemon":
+		if cfg.StatePath == "" {
+			cfg.StatePath = defaultStatePath
+		}
+		fallthrough
+	case "serve":
+		// No dashboard here, log to stderr unless asked otherwise.
+		cfg.LogFile = os.Getenv("LOG_FILE")
+	case "":
+	default:
+		log.Fatalf("unbekannter Befehl %q", cmd)
+	}
 
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer cancel()
+	schedule, err := watcher.ParseSchedule(cfg.Schedule)
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch {
+	case cfg.PickMode != "" && cfg.PickMode != "tui" && cfg.PickMode != "web":
+		log.Fatalf("PICK_MODE %q unbekannt (tui oder web)", cfg.PickMode)
+	case cfg.PickMode == "tui" && !cfg.Dashboard:
+		log.Fatal("PICK_MODE=tui benötigt das Dashboard")
+	case cfg.PickMode == "web" && cfg.HTTPAddr == "":
+		log.Fatal("PICK_MODE=web benötigt HTTP_ADDR")
+	}
 
-	req, err := tui.Run(ctx, cfg)
+	baseLevel, err := logging.ParseLevel(cfg.LogLevel)
 	if err != nil {
 		log.Fatal(err)
 	}
 	if os.Getenv("DEBUG") == "true" {
-		if b, e := json.MarshalIndent(req, "", "  "); e == nil {
-			log.Printf("TUI fertig, BookingRequest:\n%s\n", string(b))
-		} else {
-			log.Printf("TUI fertig, BookingRequest: %+v\n", req)
+		baseLevel = slog.LevelDebug
+	}
+	level := new(slog.LevelVar)
+	level.Set(baseLevel)
+	logOut := io.Writer(os.Stderr)
+	if cfg.LogFile != "" {
+		f, err := os.OpenFile(cfg.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			log.Fatal(err)
 		}
+		defer f.Close()
+		logOut = f
 	}
+	logger, err := logging.New(logOut, cfg.LogFormat, level)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !cfg.LogUnredacted {
+		logger = logging.Redact(logger)
+	}
+	slog.SetDefault(logger)
 
-	loc, _ := time.LoadLocation(req.TZ)
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	toggleLevelOnSignal(ctx, level, baseLevel)
 
 	runHeadless := cfg.Headless
 	if os.Getenv("DEBUG") == "true" {
 		runHeadless = false
 	}
 
-	drv, err := drvcdp.NewDriver(runHeadless, loc)
+	wcfg := watcher.Config{
+		BaseURL:     cfg.BaseURL,
+		Headless:    runHeadless,
+		PollMinSec:  cfg.PollMin,
+		PollMaxSec:  cfg.PollMax,
+		Schedule:    schedule,
+		WholeSlot:   cfg.WholeSlot,
+		Logger:      logger,
+		Unredacted:  cfg.LogUnredacted,
+		PickTimeout: time.Duration(cfg.PickTimeoutSec) * time.Second,
+		CodeTimeout: codeTimeout(cfg),
+	}
+	if wcfg.Code, err = mailbox(cfg, logger); err != nil {
+		log.Fatal(err)
+	}
+	wcfg.Adaptive = watcher.Adaptive{
+		Min: time.Duration(cfg.AdaptiveMinSec) * time.Second,
+		Max: time.Duration(cfg.AdaptiveMaxSec) * time.Second,
+	}
+	if cfg.HistoryPath != "" {
+		wcfg.History = history.Open(cfg.HistoryPath)
+	}
+
+	if cmd == "serve" || cmd == "daemon" {
+		if err := runServe(ctx, cfg, wcfg); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	req, err := tui.Run(ctx, cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if logger.Enabled(ctx, slog.LevelDebug) {
+		dump := req
+		if !cfg.LogUnredacted {
+			dump = req.Redacted()
+		}
+		if b, e := json.Marshal(dump); e == nil {
+			logger.Debug("TUI done", "request", json.RawMessage(b))
+		}
+	}
 
-	wcfg := watcher.Config{
-		BaseURL:    cfg.BaseURL,
-		Headless:   runHeadless,
-		PollMinSec: cfg.PollMin,
-		PollMaxSec: cfg.PollMax,
+	// Stats are kept per service, as in serve.
+	if cfg.StatsPath != "" {
+		if wcfg.Adaptive.Stats, err = serviceStats(cfg.StatsPath)(req); err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	if err := watcher.Run(ctx, drv, wcfg, req); err != nil {
+	loc, _ := time.LoadLocation(req.TZ)
+
+	drv, err := drvcdp.NewDriver(drvcdp.Options{
+		Headless:   runHeadless,
+		Loc:        loc,
+		Logger:     logger,
+		Unredacted: cfg.LogUnredacted,
+
+		ArtifactsDir:     cfg.ArtifactsDir,
+		ArtifactsKeep:    cfg.ArtifactsKeep,
+		RecordDir:        cfg.RecordDir,
+		RecordKeep:       cfg.RecordKeep,
+		ConsentSelectors: cfg.ConsentSelectors,
+		ProfileDir:       cfg.ProfileDir,
+		ReuseCalendar:    cfg.ReuseCalendar,
+	})
+	if err != nil {
 		log.Fatal(err)
 	}
-	log.Println("✅ Termin gebucht!")
+
+	status := watcher.NewStatus()
+	wcfg.Observers = append(wcfg.Observers, status)
+	wcfg.Control = watcher.NewControl()
+
+	var tuiPicker *tui.Picker
+	if cfg.PickMode == "tui" {
+		tuiPicker = tui.NewPicker()
+		wcfg.Picker = tuiPicker
+	}
+
+	var codePrompt *tui.CodePrompt
+	if cfg.Dashboard {
+		codePrompt = tui.NewCodePrompt()
+		wcfg.Code = watcher.AnyCode(codePrompt, wcfg.Code)
+	} else {
+		wcfg.Code = watcher.AnyCode(stdinCode{}, wcfg.Code)
+	}
+
+	if cfg.HTTPAddr != "" {
+		m := metrics.NewWatcher()
+		wcfg.Observers = append(wcfg.Observers, m)
+
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", m)
+		hc := &health.Checker{
+			Status:  status,
+			Browser: drv,
+			Stale:   time.Duration(cfg.HealthStaleSec) * time.Second,
+		}
+		hc.Register(mux)
+		if cfg.PickMode == "web" {
+			wp := &picker.Web{URL: localURL(cfg.HTTPAddr) + "/pick", Loc: loc, Logger: logger}
+			wp.Register(mux)
+			wcfg.Picker = wp
+		}
+		serveHTTP(ctx, cfg.HTTPAddr, mux)
+	}
+
+	if err := runWatcher(ctx, cfg, drv, wcfg, status, tuiPicker, codePrompt, req, loc); err != nil {
+		// Quitting the dashboard or Ctrl+C is not a failure.
+		if errors.Is(err, context.Canceled) {
+			fmt.Println("👋 Beendet, kein Termin gebucht.")
+			return
+		}
+		fmt.Fprintln(os.Stderr, "❌", err)
+		os.Exit(1)
+	}
+	fmt.Println("✅ Termin gebucht!")
+}
+
+// runWatcher runs the watcher, with the live dashboard in front of it if enabled.
+func runWatcher(ctx context.Context, cfg config.Config, drv browser.Driver, wcfg watcher.Config, status *watcher.Status, p *tui.Picker, codes *tui.CodePrompt, req domain.BookingRequest, loc *time.Location) error {
+	if !cfg.Dashboard {
+		return watcher.Run(ctx, drv, wcfg, req)
+	}
+
+	runCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	var runErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runErr = watcher.Run(runCtx, drv, wcfg, req)
+	}()
+
+	err := tui.RunDashboard(ctx, tui.DashboardOptions{
+		Status:  status,
+		Control: wcfg.Control,
+		Picker:  p,
+		Code:    codes,
+		Done:    done,
+		Loc:     loc,
+	})
+	if err != nil {
+		stop()
+		<-done
+		return err
+	}
+	return runErr
 }
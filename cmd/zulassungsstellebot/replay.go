@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/browser/replay"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/config"
+
+	drvcdp "github.com/mlentzler/ZulassungsstelleBot/internal/browser/chromedp"
+)
+
+// runReplay serves a flow recorded with RECORD_DIR. With -list the driver
+// runs against it right away and prints the slots it finds.
+func runReplay(cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	dir := fs.String("dir", "", "directory of one recorded flow (contains manifest.json)")
+	addr := fs.String("addr", "127.0.0.1:8089", "listen address")
+	list := fs.Bool("list", false, "run StartFlow and ListSlots against the recording, print the slots and exit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dir == "" {
+		return fmt.Errorf("replay: keine Aufzeichnung angegeben (-dir)")
+	}
+	srv, err := replay.NewServer(*dir)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	mux := http.NewServeMux()
+	srv.Register(mux)
+	serveHTTP(ctx, *addr, mux)
+	url := localURL(*addr) + "/"
+
+	if !*list {
+		fmt.Printf("▶️  Aufzeichnung vom %s mit %d Seiten auf %s\n", srv.Rec.Started.Format("02.01.2006 15:04"), len(srv.Rec.Pages), url)
+		fmt.Printf("   BASE_URL=%s setzen, um den Bot dagegen laufen zu lassen\n", url)
+		<-ctx.Done()
+		return nil
+	}
+
+	if srv.Rec.Reused {
+		return fmt.Errorf("replay: die Aufzeichnung beginnt beim neu geladenen Kalender, -list braucht eine mit Menü")
+	}
+	loc, err := time.LoadLocation(cfg.TZ)
+	if err != nil {
+		return fmt.Errorf("replay: %w", err)
+	}
+	drv, err := drvcdp.NewDriver(drvcdp.Options{Headless: true, Loc: loc})
+	if err != nil {
+		return err
+	}
+	defer drv.Close(ctx)
+
+	if err := drv.StartFlow(ctx, url, srv.Rec.Titles, srv.Rec.Selectors); err != nil {
+		return err
+	}
+	slots, err := drv.ListSlots(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d Termine gefunden\n", len(slots))
+	for _, s := range slots {
+		fmt.Println("  ", s.Start.In(loc).Format("Mon 02.01.2006 15:04"))
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/config"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/history"
+)
+
+func runReport(cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	path := fs.String("file", cfg.HistoryPath, "JSONL history written by the watcher (HISTORY_PATH)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *path == "" {
+		return fmt.Errorf("report: keine Verlaufsdatei angegeben (-file oder HISTORY_PATH)")
+	}
+
+	loc, err := time.LoadLocation(cfg.TZ)
+	if err != nil {
+		return fmt.Errorf("report: %w", err)
+	}
+	snaps, err := history.Load(*path)
+	if err != nil {
+		return err
+	}
+	history.WriteReport(os.Stdout, history.Analyze(snaps, loc), loc)
+	return nil
+}
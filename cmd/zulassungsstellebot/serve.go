@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/browser"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/config"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/control"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/domain"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/metrics"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/secret"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/watcher"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/webui"
+
+	drvcdp "github.com/mlentzler/ZulassungsstelleBot/internal/browser/chromedp"
+)
+
+// runServe runs without the TUI and lets watches be controlled over the
+// REST API on HTTP_ADDR, and optionally the web UI, until ctx is done. With
+// STATE_PATH set the watches survive restarts.
+func runServe(ctx context.Context, cfg config.Config, wcfg watcher.Config) error {
+	if cfg.HTTPAddr == "" {
+		return fmt.Errorf("serve: HTTP_ADDR ist nicht gesetzt")
+	}
+	// Anyone reaching the API can book in someone else's name.
+	if cfg.APIToken == "" && !loopback(cfg.HTTPAddr) {
+		return fmt.Errorf("serve: HTTP_ADDR %s ist von anderen Rechnern erreichbar, API_TOKEN setzen oder an 127.0.0.1 binden", cfg.HTTPAddr)
+	}
+
+	menu, err := config.LoadMenu(cfg.MenuPath)
+	if err != nil {
+		return fmt.Errorf("lade menu: %w", err)
+	}
+
+	m := metrics.NewWatcher()
+	wcfg.Observers = append(wcfg.Observers, m)
+
+	mgr := control.NewManager(ctx, wcfg, func(id string, req domain.BookingRequest) (browser.Driver, error) {
+		loc, err := time.LoadLocation(req.TZ)
+		if err != nil {
+			return nil, err
+		}
+		return drvcdp.NewDriver(drvcdp.Options{
+			Headless:   wcfg.Headless,
+			Loc:        loc,
+			Logger:     wcfg.Logger,
+			Unredacted: wcfg.Unredacted,
+
+			ArtifactsDir:     cfg.ArtifactsDir,
+			ArtifactsKeep:    cfg.ArtifactsKeep,
+			RecordDir:        cfg.RecordDir,
+			RecordKeep:       cfg.RecordKeep,
+			ConsentSelectors: cfg.ConsentSelectors,
+			ProfileDir:       profileDir(cfg.ProfileDir, id),
+			ReuseCalendar:    cfg.ReuseCalendar,
+		})
+	})
+	if cfg.StatsPath != "" {
+		mgr.StatsFor(serviceStats(cfg.StatsPath))
+	}
+	mgr.OnForget(func(id string) {
+		if err := removeProfile(cfg.ProfileDir, id); err != nil {
+			wcfg.Logger.Warn("removing browser profile failed", "watch", id, "err", err)
+		}
+	})
+
+	if cfg.StatePath != "" {
+		box, err := stateBox(cfg)
+		if err != nil {
+			return err
+		}
+		if box == nil {
+			wcfg.Logger.Warn("state file is not encrypted, set STATE_PASSPHRASE or STATE_KEY_FILE", "path", cfg.StatePath)
+		}
+		st, err := control.LoadState(cfg.StatePath, box)
+		if err != nil {
+			return err
+		}
+		n, err := mgr.Restore(st)
+		if err != nil {
+			return err
+		}
+		wcfg.Logger.Info("state restored", "path", cfg.StatePath, "resumed", n)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", m)
+	api := &control.API{Manager: mgr, DefaultTZ: cfg.TZ, Menu: &menu, Token: cfg.APIToken}
+	api.Register(mux)
+	if cfg.WebUI {
+		webui.Register(mux)
+		wcfg.Logger.Info("web UI available", "url", localURL(cfg.HTTPAddr)+"/")
+	}
+	serveHTTP(ctx, cfg.HTTPAddr, mux)
+
+	<-ctx.Done()
+	mgr.Wait()
+	return nil
+}
+
+// stateBox returns the box encrypting the state file, or nil if no key is
+// configured.
+func stateBox(cfg config.Config) (*secret.Box, error) {
+	switch {
+	case cfg.StatePassphrase != "" && cfg.StateKeyFile != "":
+		return nil, fmt.Errorf("STATE_PASSPHRASE und STATE_KEY_FILE schließen sich aus")
+	case cfg.StatePassphrase != "":
+		return secret.FromPassphrase(cfg.StatePassphrase)
+	case cfg.StateKeyFile != "":
+		return secret.FromKeyFile(cfg.StateKeyFile)
+	}
+	return nil, nil
+}
+
+// profileDir gives every watch its own Chrome profile below dir, Chrome
+// refuses to share one between running browsers. A resumed watch gets its
+// profile back.
+func profileDir(dir, id string) string {
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "watch-"+id)
+}
+
+// removeProfile deletes the profile of watch id, it holds the site's
+// cookies for that person.
+func removeProfile(dir, id string) error {
+	if dir == "" {
+		return nil
+	}
+	return os.RemoveAll(profileDir(dir, id))
+}
+
+// serviceStats loads the stats of each service from its own file next to
+// path, e.g. stats-1a2b3c4d5e6f.json. Watches for the same service share
+// them.
+func serviceStats(path string) func(req domain.BookingRequest) (*watcher.Stats, error) {
+	var mu sync.Mutex
+	loaded := map[string]*watcher.Stats{}
+	return func(req domain.BookingRequest) (*watcher.Stats, error) {
+		sum := sha256.Sum256([]byte(strings.Join(req.Menu.Path, "\x00")))
+		ext := filepath.Ext(path)
+		p := strings.TrimSuffix(path, ext) + "-" + hex.EncodeToString(sum[:6]) + ext
+
+		mu.Lock()
+		defer mu.Unlock()
+		if st, ok := loaded[p]; ok {
+			return st, nil
+		}
+		st, err := watcher.LoadStats(p)
+		if err != nil {
+			return nil, err
+		}
+		loaded[p] = st
+		return st, nil
+	}
+}
@@ -0,0 +1,108 @@
+package chromedpdrv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+const captureTimeout = 10 * time.Second
+
+// personalSteps show the entered personal data or the verification code.
+// Their page is only saved with Unredacted.
+var personalSteps = map[string]bool{"FillAndContinue": true, "EnterCode": true}
+
+// captureOnError saves a screenshot, the outer HTML and the URL of the
+// current page if *err is set. Nothing is captured when the caller gave up,
+// the page is usually fine then.
+func (d *Driver) captureOnError(step string, err *error) {
+	if *err == nil || d.artifactsDir == "" || d.artifactsKeep <= 0 {
+		return
+	}
+	if errors.Is(*err, context.Canceled) {
+		return
+	}
+	dir, cerr := d.capture(step, *err)
+	if cerr != nil {
+		d.log.Warn("capturing failure artifacts failed", "step", step, "err", cerr)
+		return
+	}
+	d.log.Info("failure artifacts saved", "step", step, "dir", dir)
+	pruneDirs(d.artifactsDir, d.artifactsKeep, d.log)
+}
+
+func (d *Driver) capture(step string, cause error) (string, error) {
+	// The context of the failed call may be expired, use the session's.
+	ctx, cancel := context.WithTimeout(d.sess.Context(), captureTimeout)
+	defer cancel()
+
+	var (
+		png  []byte
+		html string
+		url  string
+	)
+	actions := []chromedp.Action{chromedp.Location(&url)}
+	skipPage := personalSteps[step] && !d.unredacted
+	if !skipPage {
+		actions = append(actions,
+			chromedp.OuterHTML("html", &html, chromedp.ByQuery),
+			chromedp.FullScreenshot(&png, 90),
+		)
+	}
+	runErr := chromedp.Run(ctx, actions...)
+
+	dir := filepath.Join(d.artifactsDir, time.Now().Format("20060102-150405.000")+"-"+step)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	info := fmt.Sprintf("step: %s\nurl: %s\nerror: %v\n", step, url, cause)
+	if runErr != nil {
+		info += fmt.Sprintf("capture error: %v\n", runErr)
+	}
+	if skipPage {
+		info += "page not saved, it shows personal data (LOG_UNREDACTED=true saves it)\n"
+	}
+	files := map[string][]byte{"error.txt": []byte(info)}
+	if html != "" {
+		files["page.html"] = []byte(html)
+	}
+	if len(png) > 0 {
+		files["screenshot.png"] = png
+	}
+	for name, b := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), b, 0o600); err != nil {
+			return dir, err
+		}
+	}
+	return dir, nil
+}
+
+// pruneDirs keeps the newest keep subdirectories of dir. Their names start
+// with a timestamp, so they sort by age.
+func pruneDirs(dir string, keep int, log *slog.Logger) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	var dirs []string
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			dirs = append(dirs, e.Name())
+		}
+	}
+	slices.Sort(dirs)
+	for len(dirs) > keep {
+		if err := os.RemoveAll(filepath.Join(dir, dirs[0])); err != nil {
+			log.Warn("removing old directory failed", "dir", dirs[0], "err", err)
+		}
+		dirs = dirs[1:]
+	}
+}
@@ -0,0 +1,132 @@
+package chromedpdrv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/chromedp"
+)
+
+// consentJS clicks the first visible element matching one of the XPaths,
+// searching the page, same-origin iframes and open shadow roots. It
+// returns the index of the XPath that matched, or -1.
+const consentJS = `(function(xps){
+  var roots = [document], seen = 0;
+  while (seen < roots.length) {
+    var root = roots[seen++];
+    var all = root.querySelectorAll("*");
+    for (var i = 0; i < all.length; i++) {
+      var el = all[i];
+      if (el.shadowRoot) roots.push(el.shadowRoot);
+      if (el.tagName === "IFRAME") {
+        try { if (el.contentDocument) roots.push(el.contentDocument); } catch (e) {}
+      }
+    }
+  }
+  function visible(el) {
+    return !el.disabled && el.getClientRects().length > 0;
+  }
+  for (var x = 0; x < xps.length; x++) {
+    for (var r = 0; r < roots.length; r++) {
+      var root = roots[r];
+      var doc = root.ownerDocument || root;
+      // Absolute paths do not reach into shadow trees.
+      var xp = root.nodeType === 11 && xps[x].charAt(0) === "/" ? "." + xps[x] : xps[x];
+      var res;
+      try {
+        res = doc.evaluate(xp, root, null, XPathResult.ORDERED_NODE_SNAPSHOT_TYPE, null);
+      } catch (e) { continue; }
+      for (var k = 0; k < res.snapshotLength; k++) {
+        var el = res.snapshotItem(k);
+        if (visible(el)) { el.click(); return x; }
+      }
+    }
+  }
+  return -1;
+})(%s)`
+
+// consentSelectors returns the configured selectors, the one that worked
+// last time first.
+func (d *Driver) consentSelectors() []string {
+	if d.consentHit == "" {
+		return d.consent
+	}
+	out := []string{d.consentHit}
+	for _, s := range d.consent {
+		if s != d.consentHit {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// dismissConsent clicks away a cookie banner if one is shown and reports
+// whether it did. Until a banner was found once it waits a moment for one
+// to appear; afterwards the consent is usually stored and one look is
+// enough.
+func (d *Driver) dismissConsent(ctx context.Context) bool {
+	xps := d.consentSelectors()
+	if len(xps) == 0 {
+		return false
+	}
+	attempts := 2
+	if d.consentHit != "" {
+		attempts = 1
+	}
+	for i := range attempts {
+		if i > 0 {
+			_ = chromedp.Run(ctx, Sleep(700))
+		}
+		if xp, ok := d.clickConsent(ctx, xps); ok {
+			d.log.Info("cookie banner dismissed", "selector", xp)
+			d.consentHit = xp
+			_ = chromedp.Run(ctx, Sleep(300))
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Driver) clickConsent(ctx context.Context, xps []string) (string, bool) {
+	c, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	arg, _ := json.Marshal(xps)
+	hit := -1
+	if err := chromedp.Run(c, chromedp.Evaluate(fmt.Sprintf(consentJS, arg), &hit)); err != nil {
+		d.log.Debug("cookie banner script failed", "err", err)
+	}
+	if hit >= 0 && hit < len(xps) {
+		return xps[hit], true
+	}
+
+	// Cross-origin iframes are out of reach for the script; the DevTools
+	// search looks into every frame.
+	for _, xp := range xps {
+		var nodes []*cdp.Node
+		if err := chromedp.Run(c, chromedp.Nodes(xp, &nodes, chromedp.BySearch, chromedp.AtLeast(0))); err != nil || len(nodes) == 0 {
+			continue
+		}
+		if err := chromedp.Run(c, chromedp.MouseClickNode(nodes[0])); err != nil {
+			d.log.Debug("cookie banner click failed", "selector", xp, "err", err)
+			continue
+		}
+		return xp, true
+	}
+	return "", false
+}
+
+// consentList puts extra selectors in front of the defaults, each one once.
+func consentList(extra []string) []string {
+	var out []string
+	for _, xp := range slices.Concat(extra, XpCookieTry) {
+		if xp != "" && !slices.Contains(out, xp) {
+			out = append(out, xp)
+		}
+	}
+	return out
+}
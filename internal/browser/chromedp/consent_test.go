@@ -0,0 +1,168 @@
+package chromedpdrv
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+func TestConsentList(t *testing.T) {
+	extra := []string{`//button[@id="ok"]`, XpCookieTry[2], `//button[@id="ok"]`, ""}
+	got := consentList(extra)
+
+	want := []string{`//button[@id="ok"]`, XpCookieTry[2], XpCookieTry[0], XpCookieTry[1], XpCookieTry[3]}
+	if !slices.Equal(got, want) {
+		t.Fatalf("consentList = %q\nwant %q", got, want)
+	}
+	if got := consentList(nil); !slices.Equal(got, XpCookieTry) {
+		t.Fatalf("consentList(nil) = %q, want the defaults", got)
+	}
+}
+
+func TestConsentSelectorsHitFirst(t *testing.T) {
+	d := &Driver{consent: consentList(nil)}
+	if got := d.consentSelectors(); !slices.Equal(got, XpCookieTry) {
+		t.Fatalf("without a hit = %q", got)
+	}
+	d.consentHit = XpCookieTry[2]
+	got := d.consentSelectors()
+	want := []string{XpCookieTry[2], XpCookieTry[0], XpCookieTry[1], XpCookieTry[3]}
+	if !slices.Equal(got, want) {
+		t.Fatalf("with a hit = %q\nwant %q", got, want)
+	}
+}
+
+// consentPages serves banners the way the sites do: in the page itself, in a
+// same-origin iframe and in an open shadow root. A click stores the button's
+// id in window.top.clicked.
+var consentPages = map[string]string{
+	"/top":    `<div id="banner"><button id="top" onclick="window.top.clicked=this.id">Akzeptieren</button></div>`,
+	"/iframe": `<p>Termine</p><iframe src="/frame"></iframe>`,
+	"/frame":  `<button id="frame" onclick="window.top.clicked=this.id">Zustimmen</button>`,
+	"/shadow": `<div id="host"></div><script>
+var root = document.getElementById("host").attachShadow({mode: "open"});
+root.innerHTML = '<button id="shadow" onclick="window.top.clicked=this.id">Einverstanden</button>';
+</script>`,
+	// Both buttons match; which one is clicked depends on the order.
+	"/both": `<button id="ok" onclick="window.top.clicked=this.id">OK</button>
+<button id="agree" onclick="window.top.clicked=this.id">Zustimmen</button>`,
+	"/none": `<p>Kein Banner</p>`,
+}
+
+func TestDismissConsent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := consentPages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, "<!doctype html><html><body>"+page+"</body></html>")
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		path, clicked string
+		hit           string
+	}{
+		{path: "/top", clicked: "top", hit: XpCookieTry[1]},
+		{path: "/iframe", clicked: "frame", hit: XpCookieTry[2]},
+		{path: "/shadow", clicked: "shadow", hit: XpCookieTry[3]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			d := testDriver(t)
+			clicked := dismiss(t, d, srv.URL+tt.path)
+			if clicked != tt.clicked {
+				t.Fatalf("clicked %q, want %q", clicked, tt.clicked)
+			}
+			if d.consentHit != tt.hit {
+				t.Fatalf("consentHit = %q, want %q", d.consentHit, tt.hit)
+			}
+		})
+	}
+
+	t.Run("hit first", func(t *testing.T) {
+		d := testDriver(t)
+		if got := dismiss(t, d, srv.URL+"/both"); got != "ok" {
+			t.Fatalf("without a hit clicked %q, want the first default", got)
+		}
+		if got := dismiss(t, d, srv.URL+"/iframe"); got != "frame" {
+			t.Fatalf("clicked %q in the iframe", got)
+		}
+		if got := dismiss(t, d, srv.URL+"/both"); got != "agree" {
+			t.Fatalf("clicked %q, want the remembered selector tried first", got)
+		}
+		if d.consentHit != XpCookieTry[2] {
+			t.Fatalf("consentHit = %q", d.consentHit)
+		}
+	})
+
+	t.Run("no banner", func(t *testing.T) {
+		d := testDriver(t)
+		if err := chromedp.Run(d.sess.ctx, chromedp.Navigate(srv.URL+"/none")); err != nil {
+			t.Fatal(err)
+		}
+		if d.dismissConsent(d.sess.ctx) {
+			t.Fatal("dismissed a banner that is not there")
+		}
+		if d.consentHit != "" {
+			t.Fatalf("consentHit = %q", d.consentHit)
+		}
+	})
+}
+
+// dismiss opens url, dismisses the banner and returns the id of the clicked
+// button.
+func dismiss(t *testing.T, d *Driver, url string) string {
+	t.Helper()
+	ctx := d.sess.ctx
+	if err := chromedp.Run(ctx, chromedp.Navigate(url)); err != nil {
+		t.Fatal(err)
+	}
+	if !d.dismissConsent(ctx) {
+		t.Fatalf("%s: no banner dismissed", url)
+	}
+	var clicked string
+	if err := chromedp.Run(ctx, chromedp.Evaluate(`window.clicked || ""`, &clicked)); err != nil {
+		t.Fatal(err)
+	}
+	return clicked
+}
+
+// testDriver starts a headless browser for one test and skips the test if
+// none is available. CHROME_PATH selects the binary.
+func testDriver(t *testing.T) *Driver {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("needs a browser")
+	}
+	opts := append(chromedp.DefaultExecAllocatorOptions[:], chromedp.NoSandbox)
+	if p := os.Getenv("CHROME_PATH"); p != "" {
+		opts = append(opts, chromedp.ExecPath(p))
+	}
+	base, stop := context.WithTimeout(context.Background(), time.Minute)
+	alloc, cancelAlloc := chromedp.NewExecAllocator(base, opts...)
+	ctx, cancel := chromedp.NewContext(alloc)
+	t.Cleanup(func() {
+		cancel()
+		cancelAlloc()
+		stop()
+	})
+	if err := chromedp.Run(ctx); err != nil {
+		t.Skipf("no browser: %v", err)
+	}
+	return &Driver{
+		sess:    &Session{alloc: alloc, ctx: ctx, cancel: cancel},
+		log:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		consent: consentList(nil),
+	}
+}
@@ -2,11 +2,12 @@ package chromedpdrv
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"os"
-	"regexp"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,39 +15,123 @@ import (
 	"github.com/chromedp/chromedp"
 
 	"github.com/mlentzler/ZulassungsstelleBot/internal/browser"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/domain"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/logging"
 )
 
 type Driver struct {
 	sess *Session
 	loc  *time.Location
+	log  *slog.Logger
+
+	unredacted    bool
+	artifactsDir  string
+	artifactsKeep int
+
+	// nodes are the slot elements of the last ListSlots by nodeKey, used as
+	// a last resort by BookSlot while the page was not reloaded.
+	nodes map[string]*cdp.Node
+
+	// consent are the XPaths of cookie banner buttons; consentHit is the
+	// one that worked last.
+	consent    []string
+	consentHit string
+
+	// calendarURL is where the last StartFlow for flowKey ended, if
+	// reuseCalendar is set.
+	reuseCalendar bool
+	flowKey       string
+	calendarURL   string
 }
 
-type slotRef struct {
-	Node *cdp.Node
-	ISO  string
-	Aria string
+type Options struct {
+	Headless bool
+	Loc      *time.Location
+	// Logger defaults to slog.Default(). Personal data is masked in its
+	// output unless Unredacted is set.
+	Logger     *slog.Logger
+	Unredacted bool
+	// ArtifactsDir receives a screenshot, the HTML and the URL of the page
+	// whenever StartFlow, BookSlot, FillAndContinue or EnterCode fail; the
+	// pages of the latter two only with Unredacted. Only the newest
+	// ArtifactsKeep captures are kept; empty or zero disables capturing.
+	ArtifactsDir  string
+	ArtifactsKeep int
+	// RecordDir records the HTML of every page state, one directory per
+	// flow, to be served by the replay package. The newest RecordKeep flows
+	// are kept.
+	RecordDir  string
+	RecordKeep int
+	// ConsentSelectors are XPaths of cookie banner buttons tried before
+	// XpCookieTry.
+	ConsentSelectors []string
+	// ProfileDir keeps the Chrome profile, and with it the site's cookies,
+	// between runs. Each driver needs its own.
+	ProfileDir string
+	// ReuseCalendar reloads the calendar on the next StartFlow for the same
+	// service instead of clicking through the menu again.
+	ReuseCalendar bool
 }
 
-func NewDriver(headless bool, loc *time.Location) (*Driver, error) {
-	s, err := New(headless)
+func NewDriver(opts Options) (*Driver, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if !opts.Unredacted {
+		logger = logging.Redact(logger)
+	}
+	logger = logger.With("component", "chromedpdrv")
+
+	s, err := New(opts.Headless, opts.ProfileDir, logger)
 	if err != nil {
 		return nil, err
 	}
-	return &Driver{sess: s, loc: loc}, nil
+	if opts.RecordDir != "" {
+		s.SetRecorder(NewRecorder(opts.RecordDir, max(opts.RecordKeep, 1), opts.Unredacted, logger))
+	}
+	return &Driver{
+		sess:          s,
+		loc:           opts.Loc,
+		log:           logger,
+		unredacted:    opts.Unredacted,
+		artifactsDir:  opts.ArtifactsDir,
+		artifactsKeep: opts.ArtifactsKeep,
+		consent:       consentList(opts.ConsentSelectors),
+		reuseCalendar: opts.ReuseCalendar,
+	}, nil
 }
 
 func (d *Driver) Open(ctx context.Context) error  { return nil }
 func (d *Driver) Close(ctx context.Context) error { d.sess.Close(); return nil }
 
-func (d *Driver) StartFlow(ctx context.Context, baseURL string, titles []string, selectors []string) error {
+// Alive reports whether the browser session still responds.
+func (d *Driver) Alive(ctx context.Context) error { return d.sess.Alive() }
+
+func (d *Driver) StartFlow(ctx context.Context, baseURL string, titles []string, selectors []string) (err error) {
+	defer d.captureOnError("StartFlow", &err)
 	c := d.sess.Context()
 
+	key := baseURL + "\x00" + strings.Join(selectors, "\x00")
+	if d.reuseCalendar && d.flowKey == key && d.calendarURL != "" {
+		d.sess.beginRecording(baseURL, titles, selectors, true)
+		rerr := d.refreshCalendar(c)
+		if rerr == nil {
+			return nil
+		}
+		d.log.Info("calendar not reusable, starting over", "reason", rerr)
+	}
+	d.flowKey, d.calendarURL = key, ""
+
+	d.sess.beginRecording(baseURL, titles, selectors, false)
 	if err := chromedp.Run(c,
 		chromedp.Navigate(baseURL),
 		chromedp.WaitReady("body", chromedp.ByQuery),
 	); err != nil {
-		return fmt.Errorf("navigate: %w", err)
+		return &browser.FlowError{Step: "navigate", Err: err}
 	}
+	d.dismissConsent(c)
+	d.sess.Snapshot("start")
 
 	for i := range selectors {
 		sel := selectors[i]
@@ -55,34 +140,47 @@ func (d *Driver) StartFlow(ctx context.Context, baseURL string, titles []string,
 			title = titles[i]
 		}
 
-		stepCtx, cancel := context.WithTimeout(c, 8*time.Second)
-		defer cancel()
-
-		var err error
-		if strings.HasPrefix(sel, "/") || strings.HasPrefix(sel, "(") {
-			err = chromedp.Run(stepCtx,
-				chromedp.WaitVisible(sel, chromedp.BySearch),
-				chromedp.ScrollIntoView(sel, chromedp.BySearch),
-				chromedp.Click(sel, chromedp.NodeVisible, chromedp.BySearch),
-			)
-		} else {
-			err = chromedp.Run(stepCtx,
-				chromedp.WaitVisible(sel, chromedp.ByQuery),
-				chromedp.ScrollIntoView(sel, chromedp.ByQuery),
-				chromedp.Click(sel, chromedp.NodeVisible, chromedp.ByQuery),
-			)
+		err := d.clickMenu(c, sel)
+		// A banner that appeared late may cover the menu.
+		if err != nil && d.dismissConsent(c) {
+			err = d.clickMenu(c, sel)
 		}
 		if err != nil {
-			return fmt.Errorf("menu step %d failed (title=%q sel=%q): %w", i+1, title, sel, err)
+			d.log.Warn("menu step failed", "step", i+1, "title", title, "selector", sel, "err", err)
+			return &browser.FlowError{Step: strconv.Itoa(i + 1), Title: title, Selector: sel, Err: err}
 		}
+		d.log.Debug("menu step done", "step", i+1, "title", title, "selector", sel)
 		_ = chromedp.Run(c, Sleep(400))
+		d.sess.Snapshot(fmt.Sprintf("menu-%d", i+1))
 	}
 
-	return chromedp.Run(c,
+	if err := chromedp.Run(c,
 		chromedp.WaitVisible(XpBookSloot, chromedp.BySearch),
 		chromedp.ScrollIntoView(XpBookSloot, chromedp.BySearch),
 		chromedp.Click(XpBookSloot, chromedp.NodeVisible, chromedp.BySearch),
 		Sleep(500),
+	); err != nil {
+		return &browser.FlowError{Step: "book", Err: err}
+	}
+	if d.reuseCalendar {
+		_ = chromedp.Run(c, chromedp.Location(&d.calendarURL))
+	}
+	d.sess.Snapshot("calendar")
+	return nil
+}
+
+func (d *Driver) clickMenu(ctx context.Context, sel string) error {
+	stepCtx, cancel := context.WithTimeout(ctx, 8*time.Second)
+	defer cancel()
+
+	by := chromedp.ByQuery
+	if strings.HasPrefix(sel, "/") || strings.HasPrefix(sel, "(") {
+		by = chromedp.BySearch
+	}
+	return chromedp.Run(stepCtx,
+		chromedp.WaitVisible(sel, by),
+		chromedp.ScrollIntoView(sel, by),
+		chromedp.Click(sel, chromedp.NodeVisible, by),
 	)
 }
 
@@ -91,8 +189,6 @@ func (d *Driver) PickDate(ctx context.Context, date time.Time) error {
 }
 
 var (
-	reTime = regexp.MustCompile(`^\s*\d{1,2}:\d{2}\s*$`)
-
 	xpDateGroups = []string{
 		`//*[@data-date]`,
 		`//section[contains(@class,"day") or contains(@class,"date")]`,
@@ -101,26 +197,6 @@ var (
 	xpTimesWithin = `.//button[normalize-space(.) and not(@disabled)] | .//a[normalize-space(.) and not(@disabled)]`
 )
 
-// robustness against different date formats
-func parseDateLabel(s string, loc *time.Location) (time.Time, bool) {
-	s = strings.TrimSpace(s)
-	// YYYY-MM-DD
-	if t, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
-		return t, true
-	}
-	// DD.MM.YYYY
-	if t, err := time.ParseInLocation("02.01.2006", s, loc); err == nil {
-		return t, true
-	}
-	// "Montag, 21.10.2025" → nach Komma
-	if i := strings.LastIndex(s, ","); i >= 0 {
-		if t, err := time.ParseInLocation("02.01.2006", strings.TrimSpace(s[i+1:]), loc); err == nil {
-			return t, true
-		}
-	}
-	return time.Time{}, false
-}
-
 func (d *Driver) ListSlots(ctx context.Context) ([]browser.Slot, error) {
 	c := d.sess.Context()
 
@@ -132,166 +208,79 @@ func (d *Driver) ListSlots(ctx context.Context) ([]browser.Slot, error) {
 [not(@disabled)]
 `
 
-	var nodes []*cdp.Node
-	if err := chromedp.Run(c, chromedp.Nodes(xpCandidates, &nodes, chromedp.BySearch)); err != nil {
+	var (
+		nodes []*cdp.Node
+		url   string
+	)
+	if err := chromedp.Run(c,
+		chromedp.Location(&url),
+		chromedp.Nodes(xpCandidates, &nodes, chromedp.BySearch),
+	); err != nil {
 		return nil, err
 	}
 
+	d.nodes = make(map[string]*cdp.Node, len(nodes))
 	if len(nodes) == 0 {
 		return nil, nil
 	}
 
-	reISO := regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:Z|[+-]\d{2}:\d{2})`)
-	reTime := regexp.MustCompile(`\b(\d{1,2}):(\d{2})\b`)
-
 	out := make([]browser.Slot, 0, len(nodes))
 	for _, n := range nodes {
-		onclick, _ := getAttr(n, "onclick")
-		aria, _ := getAttr(n, "aria-label")
-		dataDatetime, _ := getAttr(n, "data-datetime")
-		dataDate, _ := getAttr(n, "data-date")
-
-		var (
-			iso string
-			ts  time.Time
-			ok  bool
-		)
-
-		if onclick != "" {
-			if m := reISO.FindString(onclick); m != "" {
-				iso = m
-			}
-		}
-		if iso == "" && dataDatetime != "" && reISO.MatchString(dataDatetime) {
-			iso = dataDatetime
-		}
-
-		if iso != "" {
-			t, err := time.Parse(time.RFC3339, iso)
-			if err == nil {
-				ts, ok = t.In(d.loc), true
-			} else {
-				d.logf("ListSlots: ISO %q parse error: %v", iso, err)
-			}
-		}
-
-		// Fallback: use aria-label to parse time
-		if !ok && aria != "" {
-			if day, dayOK := parseDateLabel(aria, d.loc); dayOK {
-				if tm := reTime.FindString(aria); tm != "" {
-					parts := strings.SplitN(tm, ":", 2)
-					hh := parts[0]
-					mm := parts[1]
-					layout := "2006-01-02 15:04"
-					composed := fmt.Sprintf("%04d-%02d-%02d %s:%s",
-						day.Year(), int(day.Month()), day.Day(), hh, mm)
-					if t, err := time.ParseInLocation(layout, composed, d.loc); err == nil {
-						ts, ok = t, true
-					} else {
-						d.logf("ListSlots: aria-label compose parse error (%q): %v", composed, err)
-					}
-				}
-			}
-		}
-
-		// 2nd Fallback: data-date + time from aria-label
-		if !ok && dataDate != "" {
-			if day, dayOK := parseDateLabel(dataDate, d.loc); dayOK {
-				var hhmm string
-				if aria != "" {
-					hhmm = reTime.FindString(aria)
-				}
-				if hhmm != "" {
-					layout := "2006-01-02 15:04"
-					composed := fmt.Sprintf("%04d-%02d-%02d %s",
-						day.Year(), int(day.Month()), day.Day(), hhmm)
-					if t, err := time.ParseInLocation(layout, composed, d.loc); err == nil {
-						ts, ok = t, true
-					}
-				}
-			}
-		}
-
-		if !ok {
-			d.logf("ListSlots: verwerfe Kandidat (kein ISO/Datum+Zeit) nodeID=%d onclick=%q aria=%q data-datetime=%q data-date=%q",
-				n.NodeID, onclick, aria, dataDatetime, dataDate)
+		attrs := nodeAttrs(n)
+		s, err := parseSlot(attrs, d.loc)
+		if err != nil {
+			d.log.Debug("slot candidate dropped", "node", n.NodeID, "reason", err,
+				"onclick", attrs["onclick"], "aria", attrs["aria-label"],
+				"data_datetime", attrs["data-datetime"], "data_date", attrs["data-date"])
 			continue
 		}
-
-		out = append(out, browser.Slot{
-			Start: ts,
-			Ref:   slotRef{Node: n, ISO: iso, Aria: aria},
-		})
-		d.logf("ListSlots: erkannt %s (ISO=%q, aria=%q)", ts.Format(time.RFC3339), iso, aria)
+		s.Ref.URL = url
+		d.nodes[nodeKey(s.Ref)] = n
+		out = append(out, s)
+		d.log.Debug("slot found", "slot", s.Start.Format(time.RFC3339), "iso", s.Ref.ISO, "aria", s.Ref.Aria)
 	}
 
-	d.logf("ListSlots: insgesamt %d Slots erkannt", len(out))
+	d.log.Debug("slots listed", "count", len(out))
 	return out, nil
 }
 
-func (d *Driver) logf(msg string, args ...any) {
-	if os.Getenv("DEBUG") == "true" {
-		log.Printf("[chromedpdrv] "+msg, args...)
-	}
-}
-
-func getAttr(n *cdp.Node, key string) (string, bool) {
-	for i := 0; i+1 < len(n.Attributes); i += 2 {
-		if n.Attributes[i] == key {
-			return n.Attributes[i+1], true
-		}
-	}
-	return "", false
-}
-
-func (d *Driver) BookSlot(ctx context.Context, s browser.Slot, form map[string]string) error {
+func (d *Driver) BookSlot(ctx context.Context, s browser.Slot, opts browser.BookingOptions) (err error) {
+	defer d.captureOnError("BookSlot", &err)
 	c := d.sess.Context()
-	d.logf("BookSlot: AUFGERUFEN start=%s refType=%T form=%s", s.Start.Format(time.RFC3339), s.Ref, d.dumpFormMap(form))
-
-	var (
-		n    *cdp.Node
-		iso  string
-		aria string
-	)
-	switch r := s.Ref.(type) {
-	case slotRef:
-		n = r.Node
-		iso = r.ISO
-		aria = r.Aria
-	case *cdp.Node:
-		n = r
-		if v, _ := getAttr(n, "aria-label"); v != "" {
-			aria = v
-		}
-		if v, _ := getAttr(n, "data-datetime"); v != "" {
-			iso = v
-		}
-		if iso == "" {
-			if v, _ := getAttr(n, "onclick"); v != "" {
-				if m := regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:Z|[+-]\d{2}:\d{2})`).FindString(v); m != "" {
-					iso = m
-				}
+	log := d.log.With("step", "BookSlot", "slot", s.Start.Format(time.RFC3339))
+	n := d.nodes[nodeKey(s.Ref)]
+	if !opts.Override.IsZero() {
+		log.Debug("locator override active", "override", opts.Override)
+		s.Ref = s.Ref.With(opts.Override)
+	}
+	log.Debug("called", "ref", s.Ref)
+	// The page leaves the calendar from here on.
+	d.calendarURL = ""
+	iso, aria := s.Ref.ISO, s.Ref.Aria
+
+	// The slot may come from an earlier page, e.g. after a reload or when
+	// booked through the control API.
+	if s.Ref.URL != "" {
+		var cur string
+		if err := chromedp.Run(c, chromedp.Location(&cur)); err == nil && cur != s.Ref.URL {
+			log.Info("slot listed on another page, navigating there", "url", s.Ref.URL, "current", cur)
+			if err := chromedp.Run(c,
+				chromedp.Navigate(s.Ref.URL),
+				chromedp.WaitReady("body", chromedp.ByQuery),
+			); err != nil {
+				return fmt.Errorf("BookSlot: Kalenderseite nicht erreichbar: %w", err)
 			}
+			n = nil
 		}
-	default:
-	}
-
-	if v := form["_forceISO"]; v != "" {
-		d.logf("BookSlot: FORCE ISO override aktiv: %s", v)
-		iso = v
-	}
-	if v := form["_forceARIA"]; v != "" {
-		d.logf("BookSlot: FORCE ARIA override aktiv: %s", v)
-		aria = v
 	}
 
 	if iso == "" && !s.Start.IsZero() {
 		iso = s.Start.Format(time.RFC3339)
-		d.logf("BookSlot: kein ISO in Ref, benutze aus Start: %s", iso)
+		log.Debug("no ISO in ref, using slot start", "iso", iso)
 	}
 	hhmm := s.Start.Format("15:04")
 
-	d.logf("BookSlot: Klick-Ziel iso=%q aria=%q hhmm=%q nodePresent=%v", iso, aria, hhmm, n != nil)
+	log.Debug("click target", "iso", iso, "aria", aria, "hhmm", hhmm, "node_present", n != nil)
 
 	var xps []string
 	if iso != "" {
@@ -302,10 +291,15 @@ func (d *Driver) BookSlot(ctx context.Context, s browser.Slot, form map[string]s
 		)
 	}
 	if aria != "" {
-		xps = append(xps,
-			`//*[@aria-label=`+xpathQuote(aria)+`]`,
-		)
+		xps = append(xps, xpathAria(aria))
 	}
+	// The data-* attributes may be shared with other slots; they are only
+	// clicked if they single out one element.
+	dataXP := xpathData(s.Ref.Data)
+	if dataXP != "" {
+		xps = append(xps, dataXP)
+	}
+	exact := len(xps)
 	xps = append(xps,
 		`//a[contains(@class,"time-container") and contains(normalize-space(.),`+xpathQuote(hhmm)+`)]`,
 		`//button[contains(normalize-space(.),`+xpathQuote(hhmm)+`)]`,
@@ -314,8 +308,22 @@ func (d *Driver) BookSlot(ctx context.Context, s browser.Slot, form map[string]s
 	// 1) XPath-Click-Versuche
 	var clickErr error
 	for i, xp := range xps {
-		d.logf("BookSlot: Versuch %d XPath=%s", i+1, xp)
+		// Before falling back to the bare time, look for a label that reads
+		// as the slot's start, e.g. "9:30 Uhr" or "Di 21.10. 09:30".
+		if i == exact && d.clickByLabel(c, log, s.Start) {
+			clickErr = nil
+			break
+		}
+		log.Debug("click attempt", "attempt", i+1, "selector", xp)
 		stepCtx, cancel := context.WithTimeout(c, 3*time.Second)
+		if xp == dataXP {
+			var found []*cdp.Node
+			if err := chromedp.Run(stepCtx, chromedp.Nodes(xp, &found, chromedp.BySearch, chromedp.AtLeast(0))); err != nil || len(found) != 1 {
+				cancel()
+				log.Debug("data attributes not unique, skipped", "selector", xp, "matches", len(found), "err", err)
+				continue
+			}
+		}
 		err := chromedp.Run(stepCtx,
 			chromedp.WaitVisible(xp, chromedp.BySearch),
 			chromedp.ScrollIntoView(xp, chromedp.BySearch),
@@ -324,19 +332,19 @@ func (d *Driver) BookSlot(ctx context.Context, s browser.Slot, form map[string]s
 		)
 		cancel()
 		if err == nil {
-			d.logf("BookSlot: Klick erfolgreich (XPath %d)", i+1)
+			log.Debug("click succeeded", "attempt", i+1, "selector", xp)
 			clickErr = nil
 			break
 		}
-		d.logf("BookSlot: Klick fehlgeschlagen (XPath %d): %v", i+1, err)
+		log.Debug("click failed", "attempt", i+1, "selector", xp, "err", err)
 		clickErr = err
 	}
 
 	// 2) Fallback: direct Node-Klick
 	if clickErr != nil && n != nil {
-		d.logf("BookSlot: Fallback MouseClickNode auf nodeID=%d", n.NodeID)
+		log.Debug("falling back to node click", "node", n.NodeID)
 		if err := chromedp.Run(c, chromedp.MouseClickNode(n), Sleep(300)); err != nil {
-			d.logf("BookSlot: MouseClickNode fehlgeschlagen: %v", err)
+			log.Debug("node click failed", "node", n.NodeID, "err", err)
 			clickErr = err
 		} else {
 			clickErr = nil
@@ -345,7 +353,7 @@ func (d *Driver) BookSlot(ctx context.Context, s browser.Slot, form map[string]s
 
 	// 3) Fallback: JS-click
 	if clickErr != nil {
-		d.logf("BookSlot: JS-Fallback click() wird versucht…")
+		log.Debug("falling back to JS click")
 		var ok bool
 		js := `
 (function(){
@@ -365,15 +373,14 @@ func (d *Driver) BookSlot(ctx context.Context, s browser.Slot, form map[string]s
 			parts = append(parts, fmt.Sprintf(`sel.push('button[onclick*=%s]');`, xpathQuote(iso)))
 		}
 		if aria != "" {
-			parts = append(parts, fmt.Sprintf(`sel.push('[aria-label=%s]');`, xpathQuote(aria)))
+			parts = append(parts, fmt.Sprintf(`sel.push('[aria-label^=%s]');`, xpathQuote(aria)))
 		}
-		parts = append(parts, fmt.Sprintf(`sel.push('a.time-container'); sel.push('button');`))
 
 		payload := fmt.Sprintf(js, strings.Join(parts, "\n  "))
 		if err := chromedp.Run(c, chromedp.EvaluateAsDevTools(payload, &ok)); err != nil {
-			d.logf("BookSlot: JS-Fallback Fehler: %v", err)
+			log.Debug("JS click failed", "err", err)
 		} else {
-			d.logf("BookSlot: JS-Fallback Ergebnis: %v", ok)
+			log.Debug("JS click done", "clicked", ok)
 			if ok {
 				clickErr = nil
 			}
@@ -382,10 +389,9 @@ func (d *Driver) BookSlot(ctx context.Context, s browser.Slot, form map[string]s
 
 	if clickErr != nil {
 		if n != nil {
-			d.logf("BookSlot: gebe OuterHTML des Kandidaten aus (nodeID=%d)…", n.NodeID)
 			xp := ""
 			if aria != "" {
-				xp = `//*[@aria-label=` + xpathQuote(aria) + `]`
+				xp = xpathAria(aria)
 			} else if iso != "" {
 				xp = `//*[@data-datetime=` + xpathQuote(iso) + `]`
 			}
@@ -393,14 +399,13 @@ func (d *Driver) BookSlot(ctx context.Context, s browser.Slot, form map[string]s
 				var html string
 				_ = chromedp.Run(c, chromedp.OuterHTML(xp, &html, chromedp.BySearch))
 				if html != "" {
-					d.logf("BookSlot: OuterHTML-Kandidat:\n%s", html)
+					log.Debug("candidate outer HTML", "node", n.NodeID, "selector", xp, "html", html)
 				}
 			}
 		}
 		return fmt.Errorf("BookSlot: kein Klick möglich: %w", clickErr)
 	}
 
-	d.logf("BookSlot: warte auf Formular/Step-2 Indikatoren…")
 	indicators := []string{
 		`//label[contains(translate(normalize-space(.),"ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÜ","abcdefghijklmnopqrstuvwxyzäöü"),"name")]`,
 		`//label[contains(translate(normalize-space(.),"ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÜ","abcdefghijklmnopqrstuvwxyzäöü"),"e-mail")]`,
@@ -408,22 +413,111 @@ func (d *Driver) BookSlot(ctx context.Context, s browser.Slot, form map[string]s
 		`//h2[contains(translate(normalize-space(.),"ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÜ","abcdefghijklmnopqrstuvwxyzäöü"),"angaben")]`,
 	}
 
+	// Without the form the click probably hit something else; going on
+	// could book the wrong slot.
 	if err := chromedp.Run(c, waitAnyVisible(indicators)); err != nil {
-		d.logf("BookSlot: Formular-Indikatoren NICHT erschienen: %v", err)
-	} else {
-		d.logf("BookSlot: Formular-Indikator sichtbar.")
+		return fmt.Errorf("BookSlot: Formular nicht erschienen: %w", err)
 	}
+	log.Debug("form indicator visible")
+	d.sess.Snapshot("form")
 
 	return nil
 }
 
-func (d *Driver) FillAndContinue(ctx context.Context, form map[string]string) error {
-	d.logf("FillAndContinue: called")
+// slotLabel holds the aria-label and the text of a link or button.
+type slotLabel struct {
+	Aria string `json:"aria"`
+	Text string `json:"text"`
+}
+
+// labelsJS lists the labels of all links and buttons in document order;
+// disabled and hidden ones get empty labels.
+const labelsJS = `Array.from(document.querySelectorAll("a,button")).map(function(el){
+  if (el.disabled || el.getClientRects().length === 0) return {aria: "", text: ""};
+  return {aria: el.getAttribute("aria-label") || "", text: el.innerText || ""};
+})`
+
+const clickNthJS = `(function(i){
+  var el = document.querySelectorAll("a,button")[i];
+  if (!el) return false;
+  el.scrollIntoView({block: "center"});
+  el.click();
+  return true;
+})(%d)`
+
+// clickByLabel clicks the link or button whose label parseLabel reads as
+// start, see matchLabel.
+func (d *Driver) clickByLabel(ctx context.Context, log *slog.Logger, start time.Time) bool {
+	c, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	var labels []slotLabel
+	if err := chromedp.Run(c, chromedp.Evaluate(labelsJS, &labels)); err != nil {
+		log.Debug("reading labels failed", "err", err)
+		return false
+	}
+	i := matchLabel(labels, start, d.loc)
+	if i < 0 {
+		log.Debug("no label matches the slot", "candidates", len(labels))
+		return false
+	}
+	var ok bool
+	if err := chromedp.Run(c, chromedp.Evaluate(fmt.Sprintf(clickNthJS, i), &ok), Sleep(350)); err != nil || !ok {
+		log.Debug("label click failed", "label", labels[i], "err", err)
+		return false
+	}
+	log.Debug("click by label succeeded", "label", labels[i])
+	return true
+}
+
+// matchLabel returns the index of the label giving start as date and time.
+// A label with only the time counts if no other label has that time and
+// no date, since the page may show several days. It returns -1 if none
+// matches.
+func matchLabel(labels []slotLabel, start time.Time, loc *time.Location) int {
+	if loc == nil {
+		loc = start.Location()
+	}
+	start = start.In(loc)
+	clock := time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute
+
+	timeOnly, n := -1, 0
+	for i, sl := range labels {
+		exact, onlyTime, otherDay := false, false, false
+		for _, s := range []string{sl.Aria, sl.Text} {
+			l, ok := parseLabel(s, loc)
+			switch {
+			case !ok:
+			case l.HasDay && l.Day.Format(time.DateOnly) != start.Format(time.DateOnly):
+				otherDay = true
+			case !l.HasStart || l.Start != clock:
+			case l.HasDay:
+				exact = true
+			default:
+				onlyTime = true
+			}
+		}
+		switch {
+		case otherDay:
+		case exact:
+			return i
+		case onlyTime:
+			timeOnly = i
+			n++
+		}
+	}
+	if n == 1 {
+		return timeOnly
+	}
+	return -1
+}
+
+func (d *Driver) FillAndContinue(ctx context.Context, p domain.PersonalData, fields []domain.FormField) (err error) {
+	defer d.captureOnError("FillAndContinue", &err)
 	c := d.sess.Context()
 
-	name := strings.TrimSpace(form["name"])
-	email := strings.TrimSpace(form["email"])
-	phone := strings.TrimSpace(form["telefon"])
+	name := strings.TrimSpace(p.Name)
+	email := strings.TrimSpace(p.Email)
+	phone := strings.TrimSpace(p.Phone)
 
 	actions := []chromedp.Action{
 		// Name
@@ -434,26 +528,36 @@ func (d *Driver) FillAndContinue(ctx context.Context, form map[string]string) er
 
 		// Telefon
 		chromedp.SendKeys(XpInputPhone, phone, chromedp.BySearch),
+	}
 
+	// Fields declared for the service, e.g. "Kennzeichen". Other values
+	// are ignored.
+	for _, f := range fields {
+		if v := strings.TrimSpace(p.Extra[f.Label]); v != "" {
+			actions = append(actions, fillField(f, v))
+		}
+	}
+
+	actions = append(actions,
 		// Checkbox (AGB)
 		chromedp.Click(XpCheckboxPrivacy, chromedp.ByQuery, chromedp.NodeVisible),
 
 		// Submit („Weiter“)
 		chromedp.WaitEnabled(XpContinue, chromedp.BySearch),
 		chromedp.Click(XpContinue, chromedp.BySearch),
-		chromedp.Sleep(1000 * time.Millisecond),
-	}
+		chromedp.Sleep(1000*time.Millisecond),
+	)
 
 	if err := chromedp.Run(c, actions...); err != nil {
 		return fmt.Errorf("FillAndContinue: %w", err)
 	}
 
-	d.logf("FillAndContinue: Formular ausgefüllt und abgeschickt.")
+	d.log.Debug("form filled and submitted", "step", "FillAndContinue")
+	d.sess.Snapshot("confirmation")
 	return nil
 }
 
 func (d *Driver) ConfirmBooking(ctx context.Context) error {
-	d.logf("ConfirmBooking: called")
 	c := d.sess.Context()
 
 	actions := []chromedp.Action{
@@ -466,15 +570,11 @@ func (d *Driver) ConfirmBooking(ctx context.Context) error {
 		return fmt.Errorf("ConfirmBooking: %w", err)
 	}
 
-	d.logf("ConfirmBooking: Termin bestätigt.")
+	d.log.Debug("booking confirmed", "step", "ConfirmBooking")
+	d.sess.Snapshot("booked")
 	return nil
 }
 
-func (d *Driver) dumpFormMap(form map[string]string) string {
-	b, _ := json.Marshal(form)
-	return string(b)
-}
-
 func waitAnyVisible(xps []string) chromedp.ActionFunc {
 	return func(ctx context.Context) error {
 		var lastErr error
@@ -494,6 +594,33 @@ func waitAnyVisible(xps []string) chromedp.ActionFunc {
 
 func xpathQuote(s string) string { return `"` + s + `"` }
 
+// xpathData matches a link or button carrying all the given data-*
+// attributes.
+func xpathData(data map[string]string) string {
+	if len(data) == 0 {
+		return ""
+	}
+	conds := make([]string, 0, len(data))
+	for _, k := range slices.Sorted(maps.Keys(data)) {
+		if strings.Contains(data[k], `"`) {
+			continue
+		}
+		conds = append(conds, "@"+k+"="+xpathQuote(data[k]))
+	}
+	if len(conds) == 0 {
+		return ""
+	}
+	return "//*[(self::a or self::button) and " + strings.Join(conds, " and ") + "]"
+}
+
+func nodeKey(l browser.Locator) string { return l.ISO + "\x00" + l.Aria }
+
+// xpathAria finds the element whose aria-label starts with aria, which
+// stableAria stripped of the changing free places.
+func xpathAria(aria string) string {
+	return `//*[starts-with(normalize-space(@aria-label),` + xpathQuote(aria) + `)]`
+}
+
 func nodeXPath(n *cdp.Node) string {
 	return fmt.Sprintf(`//*[@node-id="%d"]`, n.NodeID)
 }
@@ -505,3 +632,79 @@ func XpInputByAnyLabel(labels ...string) string {
 	}
 	return strings.Join(parts, " | ")
 }
+
+func (d *Driver) NeedsCode(ctx context.Context) (bool, error) {
+	c, cancel := context.WithTimeout(d.sess.Context(), 5*time.Second)
+	defer cancel()
+	var nodes []*cdp.Node
+	if err := chromedp.Run(c, chromedp.Nodes(XpCodeInput, &nodes, chromedp.BySearch, chromedp.AtLeast(0))); err != nil {
+		return false, fmt.Errorf("NeedsCode: %w", err)
+	}
+	if len(nodes) > 0 {
+		d.log.Info("verification code requested", "step", "NeedsCode")
+		d.sess.Snapshot("code")
+	}
+	return len(nodes) > 0, nil
+}
+
+func (d *Driver) EnterCode(ctx context.Context, code string) (err error) {
+	defer d.captureOnError("EnterCode", &err)
+	c := d.sess.Context()
+
+	if err := chromedp.Run(c,
+		chromedp.SetValue(XpCodeInput, "", chromedp.BySearch),
+		chromedp.SendKeys(XpCodeInput, strings.TrimSpace(code), chromedp.BySearch),
+		chromedp.WaitEnabled(XpCodeSubmit, chromedp.BySearch),
+		chromedp.Click(XpCodeSubmit, chromedp.BySearch),
+		chromedp.Sleep(1000*time.Millisecond),
+	); err != nil {
+		return fmt.Errorf("EnterCode: %w", err)
+	}
+
+	// The site shows the input again if it rejected the code.
+	again, err := d.NeedsCode(ctx)
+	if err != nil {
+		return err
+	}
+	if again {
+		return errors.New("EnterCode: Code nicht akzeptiert")
+	}
+	d.log.Debug("verification code accepted", "step", "EnterCode")
+	return nil
+}
+
+// xpSessionExpired matches notices of an expired session on a reloaded
+// calendar.
+const xpSessionExpired = `//*[contains(normalize-space(.),"abgelaufen") or contains(normalize-space(.),"Sitzung beendet")` +
+	` or contains(translate(normalize-space(.),"EXPIRED","expired"),"expired")]`
+
+// refreshCalendar loads the calendar of the last StartFlow again. It fails
+// if the site redirected elsewhere or shows the start page, e.g. because
+// the session expired.
+func (d *Driver) refreshCalendar(ctx context.Context) error {
+	c, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	var url string
+	var start, expired []*cdp.Node
+	if err := chromedp.Run(c,
+		chromedp.Navigate(d.calendarURL),
+		chromedp.WaitReady("body", chromedp.ByQuery),
+		chromedp.Location(&url),
+		chromedp.Nodes(XpBookSloot, &start, chromedp.BySearch, chromedp.AtLeast(0)),
+		chromedp.Nodes(xpSessionExpired, &expired, chromedp.BySearch, chromedp.AtLeast(0)),
+	); err != nil {
+		return err
+	}
+	switch {
+	case url != d.calendarURL:
+		return fmt.Errorf("redirected to %s", url)
+	case len(start) > 0:
+		return errors.New("start page shown")
+	case len(expired) > 0:
+		return errors.New("session expired")
+	}
+	d.log.Debug("calendar refreshed", "url", url)
+	d.sess.Snapshot("calendar")
+	return nil
+}
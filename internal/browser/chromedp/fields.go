@@ -0,0 +1,93 @@
+package chromedpdrv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/domain"
+)
+
+// XpFieldByLabel finds the input, textarea or select inside a label with
+// the given text, or else the first one following it.
+func XpFieldByLabel(label string) string {
+	const control = `*[self::input or self::textarea or self::select]`
+	l := `//label[contains(normalize-space(.), ` + xpathQuote(label) + `)]`
+	return `(` + l + `//` + control + ` | ` + l + `/following::` + control + `[1])[1]`
+}
+
+// fieldTimeout bounds filling a single extra field, so a label missing on
+// the page fails the step instead of waiting forever.
+const fieldTimeout = 10 * time.Second
+
+// fillField returns the action entering v into the extra field f.
+func fillField(f domain.FormField, v string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, fieldTimeout)
+		defer cancel()
+		return chromedp.Run(ctx, fieldAction(f, v))
+	})
+}
+
+func fieldAction(f domain.FormField, v string) chromedp.Action {
+	sel := f.Selector
+	if sel == "" {
+		sel = XpFieldByLabel(f.Label)
+	}
+	switch f.Kind() {
+	case domain.FieldCheckbox:
+		want, _ := domain.ParseYes(v)
+		return chromedp.ActionFunc(func(ctx context.Context) error {
+			var checked bool
+			if err := chromedp.Run(ctx, chromedp.JavascriptAttribute(sel, "checked", &checked, chromedp.BySearch)); err != nil {
+				return fmt.Errorf("%s: %w", f.Label, err)
+			}
+			if checked == want {
+				return nil
+			}
+			return chromedp.Run(ctx, chromedp.Click(sel, chromedp.BySearch))
+		})
+	case domain.FieldSelect:
+		return chromedp.ActionFunc(func(ctx context.Context) error {
+			xp, _ := json.Marshal(sel)
+			text, _ := json.Marshal(v)
+			var ok bool
+			if err := chromedp.Run(ctx,
+				chromedp.WaitVisible(sel, chromedp.BySearch),
+				chromedp.Evaluate(fmt.Sprintf(selectJS, xp, text), &ok),
+			); err != nil {
+				return fmt.Errorf("%s: %w", f.Label, err)
+			}
+			if !ok {
+				return fmt.Errorf("%s: option %q not found", f.Label, v)
+			}
+			return nil
+		})
+	default:
+		return chromedp.ActionFunc(func(ctx context.Context) error {
+			if err := chromedp.Run(ctx, chromedp.SendKeys(sel, strings.TrimSpace(v), chromedp.BySearch)); err != nil {
+				return fmt.Errorf("%s: %w", f.Label, err)
+			}
+			return nil
+		})
+	}
+}
+
+// selectJS picks the option of the select at an XPath by its text or value.
+const selectJS = `(function(xp, text){
+  var el = document.evaluate(xp, document, null, XPathResult.FIRST_ORDERED_NODE_TYPE, null).singleNodeValue;
+  if (!el || !el.options) return false;
+  for (var i = 0; i < el.options.length; i++) {
+    var o = el.options[i];
+    if (o.text.trim() === text || o.value === text) {
+      el.value = o.value;
+      el.dispatchEvent(new Event("change", {bubbles: true}));
+      return true;
+    }
+  }
+  return false;
+})(%s, %s)`
@@ -0,0 +1,223 @@
+package chromedpdrv
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateLabel is what parseLabel found in a date/time label. Start and End
+// are offsets from midnight of Day.
+type dateLabel struct {
+	Day    time.Time
+	HasDay bool
+	// NoYear is set if the label had no year, e.g. "Di 21.10."; Day is
+	// then the next such date from today.
+	NoYear   bool
+	Start    time.Duration
+	HasStart bool
+	End      time.Duration
+	HasEnd   bool
+}
+
+// at returns Day at offset d, in the location of Day.
+func (l dateLabel) at(d time.Duration) time.Time {
+	return time.Date(l.Day.Year(), l.Day.Month(), l.Day.Day(),
+		int(d/time.Hour), int(d%time.Hour/time.Minute), 0, 0, l.Day.Location())
+}
+
+var months = map[string]time.Month{
+	"januar": 1, "jänner": 1, "january": 1, "jan": 1,
+	"februar": 2, "february": 2, "feb": 2,
+	"märz": 3, "maerz": 3, "march": 3, "mär": 3, "mrz": 3, "mar": 3,
+	"april": 4, "apr": 4,
+	"mai": 5, "may": 5,
+	"juni": 6, "june": 6, "jun": 6,
+	"juli": 7, "july": 7, "jul": 7,
+	"august": 8, "aug": 8,
+	"september": 9, "sept": 9, "sep": 9,
+	"oktober": 10, "october": 10, "okt": 10, "oct": 10,
+	"november": 11, "nov": 11,
+	"dezember": 12, "december": 12, "dez": 12, "dec": 12,
+}
+
+var (
+	reDateISO = regexp.MustCompile(`\b(\d{4})-(\d{2})-(\d{2})\b`)
+	reDateNum = regexp.MustCompile(`\b(\d{1,2})\.(\d{1,2})\.(\d{4})\b`)
+	// "21. Oktober 2025", "21 October 2025"
+	reDateDayMonth = regexp.MustCompile(`\b(\d{1,2})\.?\s+(\pL+)\.?\s+(\d{4})\b`)
+	// "October 21, 2025", "Oct 21st 2025"
+	reDateMonthDay = regexp.MustCompile(`\b(\pL+)\.?\s+(\d{1,2})(?:st|nd|rd|th)?,?\s+(\d{4})\b`)
+	// Without a year: "21.10.", "21. Oktober", "October 21"
+	reDateNumShort      = regexp.MustCompile(`\b(\d{1,2})\.(\d{1,2})\.(?:[^\d]|$)`)
+	reDateDayMonthShort = regexp.MustCompile(`\b(\d{1,2})\.?\s+(\pL+)\b\.?`)
+	reDateMonthDayShort = regexp.MustCompile(`\b(\pL+)\.?\s+(\d{1,2})(?:st|nd|rd|th)?\b`)
+
+	// "09:30", "9.30 Uhr", "9:30 pm", "9 Uhr"
+	reClock = regexp.MustCompile(`\b(\d{1,2})(?:[:.](\d{2}))?\s*(uhr|h\b|am\b|pm\b|a\.m\.|p\.m\.)?`)
+	reRange = regexp.MustCompile(`^\s*(?:uhr\s*)?(?:-|–|—|bis|to|until)\s*$`)
+)
+
+// parseLabel reads a date and a time or time range from labels such as
+// "2025-10-21", "Dienstag, 21.10.2025", "Dienstag, 21. Oktober 2025 um
+// 09:30 Uhr", "Tuesday, October 21, 2025 9:30 AM" or "09:30–09:45 Uhr".
+// ok is false if neither a date nor a time was found.
+func parseLabel(s string, loc *time.Location) (l dateLabel, ok bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	rest := s
+	if day, span, found := findDate(s, loc); found {
+		l.Day, l.HasDay = day, true
+		rest = s[:span[0]] + " " + s[span[1]:]
+	} else if day, span, found := findDateNoYear(s, loc); found {
+		l.Day, l.HasDay, l.NoYear = day, true, true
+		rest = s[:span[0]] + " " + s[span[1]:]
+	}
+
+	clocks := reClock.FindAllStringSubmatchIndex(rest, -1)
+	prevEnd := -1
+	for _, m := range clocks {
+		d, valid := clockOf(rest, m)
+		if !valid {
+			continue
+		}
+		switch {
+		case !l.HasStart:
+			l.Start, l.HasStart = d, true
+		case !l.HasEnd && reRange.MatchString(rest[prevEnd:m[0]]):
+			l.End, l.HasEnd = d, true
+		}
+		prevEnd = m[1]
+		if l.HasEnd {
+			break
+		}
+	}
+	if l.HasEnd && l.End <= l.Start {
+		l.HasEnd = false
+	}
+	return l, l.HasDay || l.HasStart
+}
+
+func findDate(s string, loc *time.Location) (time.Time, []int, bool) {
+	if m := reDateISO.FindStringSubmatchIndex(s); m != nil {
+		if t, ok := ymd(s[m[2]:m[3]], s[m[4]:m[5]], s[m[6]:m[7]], loc); ok {
+			return t, m[:2], true
+		}
+	}
+	if m := reDateNum.FindStringSubmatchIndex(s); m != nil {
+		if t, ok := ymd(s[m[6]:m[7]], s[m[4]:m[5]], s[m[2]:m[3]], loc); ok {
+			return t, m[:2], true
+		}
+	}
+	for _, m := range reDateDayMonth.FindAllStringSubmatchIndex(s, -1) {
+		if mo, ok := months[s[m[4]:m[5]]]; ok {
+			if t, ok := ymd(s[m[6]:m[7]], strconv.Itoa(int(mo)), s[m[2]:m[3]], loc); ok {
+				return t, m[:2], true
+			}
+		}
+	}
+	for _, m := range reDateMonthDay.FindAllStringSubmatchIndex(s, -1) {
+		if mo, ok := months[s[m[2]:m[3]]]; ok {
+			if t, ok := ymd(s[m[6]:m[7]], strconv.Itoa(int(mo)), s[m[4]:m[5]], loc); ok {
+				return t, m[:2], true
+			}
+		}
+	}
+	return time.Time{}, nil, false
+}
+
+// now is the reference for dates without a year.
+var now = time.Now
+
+// findDateNoYear finds a day and month without a year and returns the next
+// such date from today. The span covers the date only, so "21.10." is not
+// read as the time 21:10.
+func findDateNoYear(s string, loc *time.Location) (time.Time, []int, bool) {
+	type dm struct {
+		day, month string
+		span       []int
+	}
+	var found []dm
+	if m := reDateNumShort.FindStringSubmatchIndex(s); m != nil {
+		found = append(found, dm{s[m[2]:m[3]], s[m[4]:m[5]], []int{m[0], m[5] + 1}})
+	}
+	for _, m := range reDateDayMonthShort.FindAllStringSubmatchIndex(s, -1) {
+		if mo, ok := months[s[m[4]:m[5]]]; ok {
+			found = append(found, dm{s[m[2]:m[3]], strconv.Itoa(int(mo)), m[:2]})
+		}
+	}
+	for _, m := range reDateMonthDayShort.FindAllStringSubmatchIndex(s, -1) {
+		if mo, ok := months[s[m[2]:m[3]]]; ok {
+			found = append(found, dm{s[m[4]:m[5]], strconv.Itoa(int(mo)), m[:2]})
+		}
+	}
+	today := now().In(loc)
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, loc)
+	for _, f := range found {
+		t, ok := ymd(strconv.Itoa(today.Year()), f.month, f.day, loc)
+		if !ok {
+			// 29.02. of the next leap year is too far away to matter.
+			continue
+		}
+		if t.Before(today) {
+			if t, ok = ymd(strconv.Itoa(today.Year()+1), f.month, f.day, loc); !ok {
+				continue
+			}
+		}
+		return t, f.span, true
+	}
+	return time.Time{}, nil, false
+}
+
+// withYear returns l with Day moved into the year of ref, for labels
+// without a year.
+func (l dateLabel) withYear(ref time.Time) dateLabel {
+	if t, ok := ymd(strconv.Itoa(ref.Year()), strconv.Itoa(int(l.Day.Month())), strconv.Itoa(l.Day.Day()), l.Day.Location()); ok {
+		l.Day, l.NoYear = t, false
+	}
+	return l
+}
+
+func ymd(y, m, d string, loc *time.Location) (time.Time, bool) {
+	yy, _ := strconv.Atoi(y)
+	mm, _ := strconv.Atoi(m)
+	dd, _ := strconv.Atoi(d)
+	t := time.Date(yy, time.Month(mm), dd, 0, 0, 0, 0, loc)
+	// time.Date normalizes, e.g. 31.02. would become March.
+	if t.Year() != yy || int(t.Month()) != mm || t.Day() != dd {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// clockOf converts a reClock match. A bare number without minutes only
+// counts as a time if followed by a unit like "Uhr".
+func clockOf(s string, m []int) (time.Duration, bool) {
+	h, _ := strconv.Atoi(s[m[2]:m[3]])
+	mins := 0
+	if m[4] >= 0 {
+		mins, _ = strconv.Atoi(s[m[4]:m[5]])
+	}
+	suffix := ""
+	if m[6] >= 0 {
+		suffix = s[m[6]:m[7]]
+	}
+	if m[4] < 0 && suffix == "" {
+		return 0, false
+	}
+	switch suffix {
+	case "pm", "p.m.":
+		if h < 12 {
+			h += 12
+		}
+	case "am", "a.m.":
+		if h == 12 {
+			h = 0
+		}
+	}
+	if h > 24 || mins > 59 || (h == 24 && mins > 0) {
+		return 0, false
+	}
+	return time.Duration(h)*time.Hour + time.Duration(mins)*time.Minute, true
+}
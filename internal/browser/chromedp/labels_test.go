@@ -0,0 +1,158 @@
+package chromedpdrv
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLabel(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(f func() time.Time) { now = f }(now)
+	now = func() time.Time { return time.Date(2025, 10, 19, 12, 0, 0, 0, loc) }
+
+	// day is YYYY-MM-DD, start and end are HH:MM; empty means not found.
+	tests := []struct {
+		in         string
+		day        string
+		noYear     bool
+		start, end string
+	}{
+		{in: "2025-10-21", day: "2025-10-21"},
+		{in: "21.10.2025", day: "2025-10-21"},
+		{in: "Dienstag, 21.10.2025", day: "2025-10-21"},
+		{in: "Dienstag, 21. Oktober 2025 um 09:30 Uhr", day: "2025-10-21", start: "09:30"},
+		{in: "Mittwoch, 5. März 2025, 14.15 Uhr", day: "2025-03-05", start: "14:15"},
+		{in: "Di., 21. Okt. 2025 9 Uhr", day: "2025-10-21", start: "09:00"},
+		{in: "Tuesday, October 21, 2025 9:30 AM", day: "2025-10-21", start: "09:30"},
+		{in: "Oct 21st 2025 12:15 pm", day: "2025-10-21", start: "12:15"},
+		{in: "21 October 2025, 12:00 a.m.", day: "2025-10-21", start: "00:00"},
+		{in: "Dienstag, 21.10.2025 09:30–09:45 Uhr", day: "2025-10-21", start: "09:30", end: "09:45"},
+		{in: "09:30 bis 10:00 Uhr", start: "09:30", end: "10:00"},
+		{in: "9:30 am to 10:15 am", start: "09:30", end: "10:15"},
+		{in: "1:30 pm - 2 pm", start: "13:30", end: "14:00"},
+		{in: "09:30 Uhr, 2 freie Plätze", start: "09:30"},
+		// The end must follow the start.
+		{in: "10:00 - 09:30", start: "10:00"},
+
+		// Without a year the next such date from today is taken.
+		{in: "Di 21.10. 09:30", day: "2025-10-21", noYear: true, start: "09:30"},
+		{in: "Do 02.01. 08:00–08:15", day: "2026-01-02", noYear: true, start: "08:00", end: "08:15"},
+		{in: "Dienstag, 21. Oktober, 09:30 Uhr", day: "2025-10-21", noYear: true, start: "09:30"},
+		{in: "Tuesday, October 21 9:30 AM", day: "2025-10-21", noYear: true, start: "09:30"},
+		{in: "19.10.", day: "2025-10-19", noYear: true},
+
+		{in: ""},
+		{in: "Termin buchen"},
+		{in: "31.02.2025"},
+		{in: "25:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			l, ok := parseLabel(tt.in, loc)
+			if want := tt.day != "" || tt.start != ""; ok != want {
+				t.Fatalf("ok = %v, want %v (%+v)", ok, want, l)
+			}
+
+			day := ""
+			if l.HasDay {
+				day = l.Day.Format("2006-01-02")
+				if l.Day.Location() != loc || l.Day.Hour() != 0 {
+					t.Errorf("day %v is not midnight in %v", l.Day, loc)
+				}
+			}
+			if day != tt.day {
+				t.Errorf("day = %q, want %q", day, tt.day)
+			}
+			if l.NoYear != tt.noYear {
+				t.Errorf("noYear = %v, want %v", l.NoYear, tt.noYear)
+			}
+			if got := clock(l.Start, l.HasStart); got != tt.start {
+				t.Errorf("start = %q, want %q", got, tt.start)
+			}
+			if got := clock(l.End, l.HasEnd); got != tt.end {
+				t.Errorf("end = %q, want %q", got, tt.end)
+			}
+		})
+	}
+}
+
+func clock(d time.Duration, ok bool) string {
+	if !ok {
+		return ""
+	}
+	return time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC).Add(d).Format("15:04")
+}
+
+func TestDateLabelWithYear(t *testing.T) {
+	defer func(f func() time.Time) { now = f }(now)
+	now = func() time.Time { return time.Date(2025, 12, 30, 12, 0, 0, 0, time.UTC) }
+
+	l, _ := parseLabel("Fr 02.01. 09:00", time.UTC)
+	if got := l.Day.Format("2006-01-02"); got != "2026-01-02" {
+		t.Fatalf("next occurrence = %s", got)
+	}
+	l = l.withYear(time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC))
+	if got := l.Day.Format("2006-01-02"); got != "2027-01-02" || l.NoYear {
+		t.Fatalf("withYear = %s, noYear %v", got, l.NoYear)
+	}
+}
+
+func TestParseSlotNoYear(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(f func() time.Time) { now = f }(now)
+	now = func() time.Time { return time.Date(2025, 12, 30, 12, 0, 0, 0, loc) }
+
+	// data-date gives the year, the label alone would mean 2026.
+	s, err := parseSlot(map[string]string{"aria-label": "Di 21.10. 09:30", "data-date": "2025-10-21"}, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2025, 10, 21, 9, 30, 0, 0, loc); !s.Start.Equal(want) {
+		t.Fatalf("start = %v, want %v", s.Start, want)
+	}
+}
+
+func TestMatchLabel(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(f func() time.Time) { now = f }(now)
+	now = func() time.Time { return time.Date(2025, 10, 19, 12, 0, 0, 0, loc) }
+	start := time.Date(2025, 10, 21, 9, 30, 0, 0, loc)
+
+	tests := []struct {
+		name   string
+		labels []slotLabel
+		want   int
+	}{
+		{"date and time in aria", []slotLabel{
+			{Aria: "Mo 20.10. 09:30", Text: "09:30"},
+			{Aria: "Di 21.10. 09:30, 2 freie Plätze", Text: "09:30"},
+		}, 1},
+		{"short hour in text", []slotLabel{
+			{Text: "Termin buchen"},
+			{Text: "9:00 Uhr"},
+			{Text: "9:30 Uhr"},
+		}, 2},
+		{"english", []slotLabel{{Aria: "Tuesday, October 21, 2025 7:30 AM"}, {Aria: "Tuesday, October 21, 2025 9:30 AM"}}, 1},
+		{"date wins over time only", []slotLabel{{Text: "09:30"}, {Aria: "21.10.2025 09:30"}}, 1},
+		{"time on several days", []slotLabel{{Text: "09:30"}, {Text: "09:30"}}, -1},
+		{"other day", []slotLabel{{Aria: "Mi 22.10. 09:30", Text: "09:30"}}, -1},
+		{"day without time", []slotLabel{{Text: "Dienstag, 21.10.2025"}}, -1},
+		{"hidden", []slotLabel{{}, {}}, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchLabel(tt.labels, start, loc); got != tt.want {
+				t.Errorf("matchLabel = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package chromedpdrv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/chromedp/chromedp"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/browser/replay"
+)
+
+// personalPages show the entered personal data or the verification code.
+// They are only recorded with unredacted set, like personalSteps.
+var personalPages = map[string]bool{"confirmation": true, "code": true, "booked": true}
+
+// Recorder saves the HTML of every page state of a flow, one directory per
+// StartFlow, in the format served by the replay package.
+type Recorder struct {
+	dir        string
+	keep       int
+	unredacted bool
+	log        *slog.Logger
+
+	mu  sync.Mutex
+	cur string
+	rec *replay.Recording
+}
+
+// NewRecorder records below dir and keeps the newest keep flows. Pages
+// showing personal data are skipped unless unredacted is set.
+func NewRecorder(dir string, keep int, unredacted bool, log *slog.Logger) *Recorder {
+	return &Recorder{dir: dir, keep: keep, unredacted: unredacted, log: log}
+}
+
+// records reports whether the page labelled label is saved.
+func (r *Recorder) records(label string) bool {
+	return r.unredacted || !personalPages[label]
+}
+
+// begin starts a new recording. Older recordings beyond keep are removed.
+func (r *Recorder) begin(baseURL string, titles, selectors []string, reused bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	r.cur = filepath.Join(r.dir, now.Format("20060102-150405.000"))
+	r.rec = &replay.Recording{Started: now, BaseURL: baseURL, Titles: titles, Selectors: selectors, Reused: reused}
+	if err := os.MkdirAll(r.cur, 0o700); err != nil {
+		r.log.Warn("recording failed", "err", err)
+		r.rec = nil
+		return
+	}
+	pruneDirs(r.dir, r.keep, r.log)
+}
+
+func (r *Recorder) add(label, url, html string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.rec == nil {
+		return nil
+	}
+	seq := len(r.rec.Pages)
+	file := fmt.Sprintf("%02d-%s.html", seq, label)
+	if err := os.WriteFile(filepath.Join(r.cur, file), []byte(html), 0o600); err != nil {
+		return err
+	}
+	r.rec.Pages = append(r.rec.Pages, replay.Page{Seq: seq, Label: label, URL: url, File: file, At: time.Now()})
+	b, err := json.MarshalIndent(r.rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(r.cur, replay.ManifestFile), b, 0o600)
+}
+
+// SetRecorder makes the session record page states, nil stops recording.
+func (s *Session) SetRecorder(r *Recorder) { s.rec = r }
+
+// beginRecording starts a recording per StartFlow; reused is set when the
+// flow only reloads the calendar.
+func (s *Session) beginRecording(baseURL string, titles, selectors []string, reused bool) {
+	if s.rec != nil {
+		s.rec.begin(baseURL, titles, selectors, reused)
+	}
+}
+
+// Snapshot records the current page under label if a recorder is set.
+func (s *Session) Snapshot(label string) {
+	if s.rec == nil || !s.rec.records(label) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, captureTimeout)
+	defer cancel()
+	var url, html string
+	err := chromedp.Run(ctx,
+		chromedp.Location(&url),
+		chromedp.OuterHTML("html", &html, chromedp.ByQuery),
+	)
+	if err == nil {
+		err = s.rec.add(label, url, html)
+	}
+	if err != nil {
+		s.rec.log.Warn("recording page failed", "label", label, "err", err)
+	}
+}
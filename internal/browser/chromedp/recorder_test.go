@@ -0,0 +1,92 @@
+package chromedpdrv
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/browser/replay"
+)
+
+func TestRecorderPersonalPages(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, unredacted := range []bool{false, true} {
+		r := NewRecorder(t.TempDir(), 1, unredacted, log)
+		for _, label := range []string{"start", "menu-1", "calendar", "form"} {
+			if !r.records(label) {
+				t.Errorf("unredacted=%v: %s not recorded", unredacted, label)
+			}
+		}
+		for label := range personalPages {
+			if r.records(label) != unredacted {
+				t.Errorf("unredacted=%v: %s recorded = %v", unredacted, label, !unredacted)
+			}
+		}
+	}
+}
+
+// TestRecorderReplay writes a recording and loads it with the replay
+// package.
+func TestRecorderReplay(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRecorder(dir, 1, false, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	r.begin("https://termine.example.org/", []string{"Zulassung"}, []string{"#zulassung"}, false)
+	for _, label := range []string{"start", "menu-1", "calendar"} {
+		if err := r.add(label, "https://termine.example.org/"+label, "<html><body>"+label+"</body></html>"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rec, err := replay.Load(r.cur)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.Pages) != 3 || rec.Pages[2].Label != "calendar" || rec.Selectors[0] != "#zulassung" {
+		t.Fatalf("recording = %+v", rec)
+	}
+}
+
+// TestReplayListSlots runs StartFlow and ListSlots against the recorded
+// flow in the replay testdata.
+func TestReplayListSlots(t *testing.T) {
+	srv, err := replay.NewServer("../replay/testdata/flow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	srv.Register(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	d := testDriver(t)
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.loc = loc
+	ctx := d.sess.ctx
+	if err := d.StartFlow(ctx, ts.URL+"/", srv.Rec.Titles, srv.Rec.Selectors); err != nil {
+		t.Fatal(err)
+	}
+	slots, err := d.ListSlots(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Time{
+		time.Date(2025, 10, 21, 9, 30, 0, 0, loc),
+		time.Date(2025, 10, 21, 9, 45, 0, 0, loc),
+	}
+	if len(slots) != len(want) {
+		t.Fatalf("got %d slots, want %d: %+v", len(slots), len(want), slots)
+	}
+	for i, s := range slots {
+		if !s.Start.Equal(want[i]) {
+			t.Errorf("slot %d starts %v, want %v", i, s.Start, want[i])
+		}
+		if s.Ref.URL != ts.URL+"/page/2" {
+			t.Errorf("slot %d listed on %q", i, s.Ref.URL)
+		}
+	}
+}
@@ -18,6 +18,23 @@ const (
 	XpCheckboxPrivacy = `label[for="IsTermsOfServiceConsentObtained"]`
 )
 
+// The step asking for the code the site emailed to confirm a booking.
+const (
+	xpLowerName = `translate(@name,"ABCDEFGHIJKLMNOPQRSTUVWXYZ","abcdefghijklmnopqrstuvwxyz")`
+	xpLowerID   = `translate(@id,"ABCDEFGHIJKLMNOPQRSTUVWXYZ","abcdefghijklmnopqrstuvwxyz")`
+	xpCodeLabel = `contains(normalize-space(.),"Bestätigungscode") or contains(normalize-space(.),"Verifizierungscode")` +
+		` or contains(normalize-space(.),"Sicherheitscode") or contains(normalize-space(.),"Code aus der E-Mail")`
+
+	XpCodeInput = `//input[not(@type="hidden") and (@autocomplete="one-time-code"` +
+		` or ` + xpLowerName + `="code" or ` + xpLowerID + `="code"` +
+		` or contains(` + xpLowerName + `,"verif") or contains(` + xpLowerID + `,"verif")` +
+		` or contains(` + xpLowerName + `,"otp") or contains(` + xpLowerID + `,"otp")` +
+		` or ancestor::label[` + xpCodeLabel + `]` +
+		` or @id=//label[` + xpCodeLabel + `]/@for)]`
+	XpCodeSubmit = `//button[contains(normalize-space(.), 'Bestätigen') or contains(normalize-space(.), 'Weiter')` +
+		` or contains(normalize-space(.), 'Prüfen') or contains(normalize-space(.), 'Senden')]`
+)
+
 const (
 	XpContinue       = `//button[contains(normalize-space(.), 'Weiter')]`
 	XpConfirmBooking = `//button[contains(normalize-space(.), 'Bestätigen')]`
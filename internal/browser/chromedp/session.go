@@ -2,7 +2,9 @@ package chromedpdrv
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
+	"os"
 	"time"
 
 	"github.com/chromedp/chromedp"
@@ -12,14 +14,23 @@ type Session struct {
 	alloc  context.Context
 	ctx    context.Context
 	cancel context.CancelFunc
+	rec    *Recorder
 }
 
-func New(headless bool) (*Session, error) {
+// New starts a browser. With profileDir set, Chrome keeps cookies and
+// storage there across runs; otherwise a temporary profile is used.
+func New(headless bool, profileDir string, logger *slog.Logger) (*Session, error) {
 	opts := append(chromedp.DefaultExecAllocatorOptions[:],
 		chromedp.Flag("headless", headless),
 		chromedp.NoFirstRun,
 		chromedp.NoDefaultBrowserCheck,
 	)
+	if profileDir != "" {
+		if err := os.MkdirAll(profileDir, 0o700); err != nil {
+			return nil, fmt.Errorf("profile dir: %w", err)
+		}
+		opts = append(opts, chromedp.UserDataDir(profileDir))
+	}
 	if !headless {
 		opts = append(opts,
 			chromedp.Flag("auto-open-devtools-for-tabs", true),
@@ -29,8 +40,12 @@ func New(headless bool) (*Session, error) {
 
 	ctx, cancel := chromedp.NewContext(
 		alloc,
-		chromedp.WithLogf(log.Printf),
-		chromedp.WithErrorf(log.Printf),
+		chromedp.WithLogf(func(format string, args ...any) {
+			logger.Debug(fmt.Sprintf(format, args...), "source", "chromedp")
+		}),
+		chromedp.WithErrorf(func(format string, args ...any) {
+			logger.Error(fmt.Sprintf(format, args...), "source", "chromedp")
+		}),
 	)
 	return &Session{alloc: alloc, ctx: ctx, cancel: cancel}, nil
 }
@@ -41,4 +56,16 @@ func (s *Session) Close() {
 		s.cancel()
 	}
 }
+
+// Alive reports whether the browser still answers a trivial evaluation.
+func (s *Session) Alive() error {
+	ctx, cancel := context.WithTimeout(s.ctx, 5*time.Second)
+	defer cancel()
+	var n int
+	if err := chromedp.Run(ctx, chromedp.Evaluate(`1`, &n)); err != nil {
+		return fmt.Errorf("browser not responding: %w", err)
+	}
+	return nil
+}
+
 func Sleep(ms int) chromedp.Action { return chromedp.Sleep(time.Duration(ms) * time.Millisecond) }
@@ -0,0 +1,195 @@
+package chromedpdrv
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/browser"
+)
+
+var (
+	reISO = regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:Z|[+-]\d{2}:\d{2})`)
+)
+
+func nodeAttrs(n *cdp.Node) map[string]string {
+	attrs := make(map[string]string, len(n.Attributes)/2)
+	for i := 0; i+1 < len(n.Attributes); i += 2 {
+		attrs[n.Attributes[i]] = n.Attributes[i+1]
+	}
+	return attrs
+}
+
+// parseSlot turns the attributes of a calendar candidate into a slot. The
+// start is taken, in order, from an ISO timestamp in onclick or
+// data-datetime, a date and time in aria-label, or data-date with the time
+// from aria-label; labels are read by parseLabel. End, Capacity and
+// Resource are filled in where the site exposes them. Ref.URL is left to
+// the caller. The error says why a candidate was dropped.
+func parseSlot(attrs map[string]string, loc *time.Location) (browser.Slot, error) {
+	aria := attrs["aria-label"]
+	label, _ := parseLabel(aria, loc)
+	if label.NoYear {
+		if day, ok := parseLabel(attrs["data-date"], loc); ok && day.HasDay && !day.NoYear {
+			label = label.withYear(day.Day)
+		}
+	}
+
+	start, iso, err := slotStart(attrs, label, loc)
+	if err != nil {
+		return browser.Slot{}, err
+	}
+	s := browser.Slot{
+		Start:    start,
+		End:      slotEnd(attrs, label, start, loc),
+		Capacity: slotCapacity(attrs, aria),
+		Resource: slotResource(attrs, aria),
+		Ref:      browser.Locator{ISO: iso, Aria: stableAria(aria), Data: dataAttrs(attrs)},
+	}
+	return s, nil
+}
+
+var capacityAttrs = []string{"data-capacity", "data-available", "data-free", "data-seats"}
+
+// dayAttrs are shared by all slots of a day and by the day's container.
+var dayAttrs = []string{"data-date", "data-day"}
+
+// dataAttrs returns the data-* attributes that identify the slot. The free
+// places change between polls and the day is shared with other slots, both
+// are left out.
+func dataAttrs(attrs map[string]string) map[string]string {
+	var data map[string]string
+	for k, v := range attrs {
+		if !strings.HasPrefix(k, "data-") || v == "" || slices.Contains(capacityAttrs, k) || slices.Contains(dayAttrs, k) {
+			continue
+		}
+		if data == nil {
+			data = make(map[string]string)
+		}
+		data[k] = v
+	}
+	return data
+}
+
+// stableAria returns aria with collapsed spaces and without the free
+// places, like dataAttrs. BookSlot matches the result as a prefix.
+func stableAria(aria string) string {
+	trim := func(s string) string {
+		return strings.Trim(strings.Join(strings.Fields(s), " "), " ,;·|-–—(")
+	}
+	m := reCapacityText.FindStringIndex(aria)
+	if m == nil {
+		return trim(aria)
+	}
+	if p := trim(aria[:m[0]]); p != "" {
+		return p
+	}
+	// "2 freie Plätze – 09:30 Uhr"
+	return strings.TrimLeft(trim(aria[m[1]:]), " ,;·|-–—)")
+}
+
+func slotStart(attrs map[string]string, label dateLabel, loc *time.Location) (time.Time, string, error) {
+	aria := attrs["aria-label"]
+	dataDatetime := attrs["data-datetime"]
+	dataDate := attrs["data-date"]
+
+	iso := reISO.FindString(attrs["onclick"])
+	if iso == "" && reISO.MatchString(dataDatetime) {
+		iso = dataDatetime
+	}
+
+	var errs []error
+	if iso != "" {
+		t, err := time.Parse(time.RFC3339, iso)
+		if err == nil {
+			return t.In(loc), iso, nil
+		}
+		errs = append(errs, fmt.Errorf("iso %q: %w", iso, err))
+	}
+
+	// Fallback: date and time from aria-label
+	if label.HasDay && label.HasStart {
+		return label.at(label.Start), iso, nil
+	}
+	if label.HasDay {
+		errs = append(errs, fmt.Errorf("aria-label %q: no time", aria))
+	}
+
+	// 2nd fallback: data-date + time from aria-label
+	if dataDate != "" && label.HasStart {
+		if day, ok := parseLabel(dataDate, loc); ok && day.HasDay {
+			return day.at(label.Start), iso, nil
+		}
+	}
+
+	if len(errs) == 0 {
+		return time.Time{}, "", errors.New("no ISO or date+time")
+	}
+	return time.Time{}, "", errors.Join(errs...)
+}
+
+// slotEnd reads the end from a time range in aria-label, data-end or
+// data-duration (minutes). It returns the zero time if none is given.
+func slotEnd(attrs map[string]string, label dateLabel, start time.Time, loc *time.Location) time.Time {
+	day := dateLabel{Day: start}
+	if label.HasEnd {
+		return day.at(label.End)
+	}
+	if v := attrs["data-end"]; v != "" {
+		if t, err := time.Parse(time.RFC3339, v); err == nil && t.After(start) {
+			return t.In(loc)
+		}
+		if l, ok := parseLabel(v, loc); ok && l.HasStart {
+			if l.HasDay {
+				day = l
+			}
+			if t := day.at(l.Start); t.After(start) {
+				return t
+			}
+		}
+	}
+	if v := attrs["data-duration"]; v != "" {
+		if m, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && m > 0 {
+			return start.Add(time.Duration(m) * time.Minute)
+		}
+	}
+	return time.Time{}
+}
+
+var (
+	reCapacity = regexp.MustCompile(`(?i)(?:noch\s+)?(\d+)\s+(?:freie?|plätze|platz|termine|places|seats|spots|available|verfügbar)`)
+	// reCapacityText is the whole phrase, e.g. "noch 2 freie Plätze".
+	reCapacityText = regexp.MustCompile(`(?i)(?:noch\s+)?\d+\s+(?:(?:freie?n?|plätze|platz|termine?|places?|seats?|spots?|slots?|available|verfügbar|frei|free|left)\b\s*)+`)
+	reResource     = regexp.MustCompile(`(?i)\b((?:schalter|raum|zimmer|counter|desk|room)\s+[\pL\d-]+)`)
+)
+
+func slotCapacity(attrs map[string]string, aria string) int {
+	for _, k := range capacityAttrs {
+		if n, err := strconv.Atoi(strings.TrimSpace(attrs[k])); err == nil && n > 0 {
+			return n
+		}
+	}
+	if m := reCapacity.FindStringSubmatch(aria); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return n
+	}
+	return 0
+}
+
+func slotResource(attrs map[string]string, aria string) string {
+	for _, k := range []string{"data-resource", "data-room", "data-counter"} {
+		if v := strings.TrimSpace(attrs[k]); v != "" {
+			return v
+		}
+	}
+	if m := reResource.FindStringSubmatch(aria); m != nil {
+		return m[1]
+	}
+	return ""
+}
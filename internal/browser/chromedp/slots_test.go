@@ -0,0 +1,137 @@
+package chromedpdrv
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/browser"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// parsed is the golden form of one candidate: its slot or why it was
+// dropped.
+type parsed struct {
+	Attrs   map[string]string `json:"attrs"`
+	Slot    *browser.Slot     `json:"slot,omitempty"`
+	Dropped string            `json:"dropped,omitempty"`
+}
+
+// TestParseSlotGolden runs parseSlot on the candidates of each
+// testdata/*.html fragment and compares the result with the .golden file
+// next to it. Run with -update after intended changes.
+func TestParseSlotGolden(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(f func() time.Time) { now = f }(now)
+	now = func() time.Time { return time.Date(2025, 10, 19, 12, 0, 0, 0, loc) }
+
+	files, err := filepath.Glob(filepath.Join("testdata", "*.html"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no fixtures: %v", err)
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			var out []parsed
+			for _, attrs := range candidates(t, file) {
+				p := parsed{Attrs: attrs}
+				if s, err := parseSlot(attrs, loc); err != nil {
+					p.Dropped = err.Error()
+				} else {
+					p.Slot = &s
+				}
+				out = append(out, p)
+			}
+			got, err := json.MarshalIndent(out, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			golden := strings.TrimSuffix(file, ".html") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s differs from the parsed slots:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+// TestParseSlotDropped makes sure every candidate of malformed.html is
+// dropped with a reason.
+func TestParseSlotDropped(t *testing.T) {
+	for _, attrs := range candidates(t, filepath.Join("testdata", "malformed.html")) {
+		s, err := parseSlot(attrs, time.UTC)
+		if err == nil {
+			t.Errorf("%v: parsed as %v, want dropped", attrs, s.Start)
+			continue
+		}
+		if err.Error() == "" {
+			t.Errorf("%v: dropped without a reason", attrs)
+		}
+	}
+}
+
+// candidates returns the attributes of the elements ListSlots would consider
+// in an HTML fragment: links and buttons with a slot class, selectTime in
+// onclick or an aria-label, unless disabled.
+func candidates(t *testing.T, file string) []map[string]string {
+	t.Helper()
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+
+	var out []map[string]string
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return out
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		el, ok := tok.(xml.StartElement)
+		if !ok || (el.Name.Local != "a" && el.Name.Local != "button") {
+			continue
+		}
+		attrs := map[string]string{}
+		for _, a := range el.Attr {
+			attrs[a.Name.Local] = a.Value
+		}
+		class := attrs["class"]
+		_, hasAria := attrs["aria-label"]
+		_, disabled := attrs["disabled"]
+		if disabled {
+			continue
+		}
+		if strings.Contains(class, "time") || strings.Contains(class, "slot") ||
+			strings.Contains(attrs["onclick"], "selectTime") || hasAria {
+			out = append(out, attrs)
+		}
+	}
+}
@@ -2,16 +2,92 @@ package browser
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/mlentzler/ZulassungsstelleBot/internal/domain"
 )
 
 type Slot struct {
-	Start time.Time
-	Ref   any
+	Start time.Time `json:"start"`
+	// End is zero if the site does not show it.
+	End time.Time `json:"end,omitzero"`
+	// Capacity is the number of free places, 0 if unknown.
+	Capacity int `json:"capacity,omitempty"`
+	// Resource names the counter or room, if the site shows one.
+	Resource string  `json:"resource,omitempty"`
+	Ref      Locator `json:"ref,omitzero"`
 }
 
+// Locator finds a slot on the calendar page again, also after the page was
+// reloaded. Any field may be empty; drivers try what is set.
+type Locator struct {
+	// ISO is the timestamp the site uses for the slot, e.g. in onclick.
+	ISO string `json:"iso,omitempty"`
+	// Aria is the aria-label without the number of free places, which
+	// changes between polls; drivers match it as a prefix.
+	Aria string `json:"aria,omitempty"`
+	// Data holds the data-* attributes of the slot element.
+	Data map[string]string `json:"data,omitempty"`
+	// URL is the calendar page the slot was listed on.
+	URL string `json:"url,omitempty"`
+}
+
+// With returns l with the fields that are set in o replaced.
+func (l Locator) With(o Locator) Locator {
+	if o.ISO != "" {
+		l.ISO = o.ISO
+	}
+	if o.Aria != "" {
+		l.Aria = o.Aria
+	}
+	if o.Data != nil {
+		l.Data = o.Data
+	}
+	if o.URL != "" {
+		l.URL = o.URL
+	}
+	return l
+}
+
+func (l Locator) IsZero() bool {
+	return l.ISO == "" && l.Aria == "" && len(l.Data) == 0 && l.URL == ""
+}
+
+// Duration is zero if End is unknown.
+func (s Slot) Duration() time.Duration {
+	if s.End.IsZero() {
+		return 0
+	}
+	return s.End.Sub(s.Start)
+}
+
+// BookingOptions controls how BookSlot finds the slot to click. They are
+// given per request, e.g. with the watch over the control API.
+type BookingOptions struct {
+	// Override replaces the fields of the slot's locator that are set in
+	// it, e.g. to click a specific element the site renders differently.
+	Override Locator `json:"override,omitzero"`
+}
+
+// FlowError reports the step at which StartFlow failed: "navigate", the
+// 1-based menu step, or "book" for the final "Termin buchen" button.
+type FlowError struct {
+	Step     string
+	Title    string
+	Selector string
+	Err      error
+}
+
+func (e *FlowError) Error() string {
+	if e.Selector == "" {
+		return fmt.Sprintf("%s: %v", e.Step, e.Err)
+	}
+	return fmt.Sprintf("menu step %s failed (title=%q sel=%q): %v", e.Step, e.Title, e.Selector, e.Err)
+}
+
+func (e *FlowError) Unwrap() error { return e.Err }
+
 type Driver interface {
 	Open(ctx context.Context) error
 	Close(ctx context.Context) error
@@ -19,24 +95,42 @@ type Driver interface {
 	StartFlow(ctx context.Context, baseURL string, titles []string, selectors []string) error
 	PickDate(ctx context.Context, date time.Time) error
 	ListSlots(ctx context.Context) ([]Slot, error)
-	BookSlot(ctx context.Context, s Slot, form map[string]string) error
-	FillAndContinue(ctx context.Context, form map[string]string) error
+	BookSlot(ctx context.Context, s Slot, opts BookingOptions) error
+	// FillAndContinue fills the booking form. fields describe the entries
+	// of p.Extra the service asks for.
+	FillAndContinue(ctx context.Context, p domain.PersonalData, fields []domain.FormField) error
 	ConfirmBooking(ctx context.Context) error
+	// NeedsCode reports whether the current page asks for a code the site
+	// emailed to confirm the booking. EnterCode submits it.
+	NeedsCode(ctx context.Context) (bool, error)
+	EnterCode(ctx context.Context, code string) error
 }
 
-func SlotMatches(av domain.Availability, slot time.Time, loc *time.Location) bool {
+// SlotMatches reports whether s starts inside the availability window.
+// With whole set the slot must also end by the window's ToHour; slots
+// without a known End only have their start checked.
+func SlotMatches(av domain.Availability, s Slot, loc *time.Location, whole bool) bool {
+	d := s.Start.In(loc)
+	fits := func(from, to int) bool {
+		if d.Hour() < from || d.Hour() >= to {
+			return false
+		}
+		if !whole || s.End.IsZero() {
+			return true
+		}
+		limit := time.Date(d.Year(), d.Month(), d.Day(), to, 0, 0, 0, loc)
+		return !s.End.After(limit)
+	}
+
 	switch av.Kind {
 	case domain.AvailOneOff:
-		d := slot.In(loc)
-		return d.Format("2006-01-02") == av.OneOff.DateISO &&
-			d.Hour() >= av.OneOff.FromHour && d.Hour() < av.OneOff.ToHour
+		return d.Format("2006-01-02") == av.OneOff.DateISO && fits(av.OneOff.FromHour, av.OneOff.ToHour)
 
 	case domain.AvailRecurring:
-		d := slot.In(loc)
 		goToDe := [...]string{"SO", "MO", "DI", "MI", "DO", "FR", "SA"}
 		wd := goToDe[d.Weekday()]
 		for _, w := range av.Recurring.Days {
-			if w.Weekday == wd && d.Hour() >= w.FromHour && d.Hour() < w.ToHour {
+			if w.Weekday == wd && fits(w.FromHour, w.ToHour) {
 				return true
 			}
 		}
@@ -0,0 +1,118 @@
+// Package replay serves pages recorded during a real run, so the driver and
+// the slot parsing can be rerun offline against the exact same HTML.
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ManifestFile = "manifest.json"
+
+// Page is one recorded page state.
+type Page struct {
+	Seq   int       `json:"seq"`
+	Label string    `json:"label"`
+	URL   string    `json:"url"`
+	File  string    `json:"file"`
+	At    time.Time `json:"at"`
+}
+
+// Recording describes one flow from StartFlow on, as written by the
+// chromedp recorder.
+type Recording struct {
+	Started   time.Time `json:"started"`
+	BaseURL   string    `json:"base_url"`
+	Titles    []string  `json:"titles"`
+	Selectors []string  `json:"selectors"`
+	// Reused recordings start on the calendar reloaded from an earlier
+	// flow, the menu pages are missing.
+	Reused bool   `json:"reused,omitempty"`
+	Pages  []Page `json:"pages"`
+}
+
+func Load(dir string) (*Recording, error) {
+	b, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+	var r Recording
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, fmt.Errorf("replay: %s: %w", ManifestFile, err)
+	}
+	if len(r.Pages) == 0 {
+		return nil, fmt.Errorf("replay: %s has no pages", dir)
+	}
+	return &r, nil
+}
+
+// Server serves the pages of a recording in order: the first page on /,
+// the others on /page/{n}. Scripts of the site are removed; instead a click
+// on anything but a form control, or a form submit, loads the next page.
+type Server struct {
+	Dir string
+	Rec *Recording
+}
+
+func NewServer(dir string) (*Server, error) {
+	r, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{Dir: dir, Rec: r}, nil
+}
+
+func (s *Server) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) { s.page(w, 0) })
+	mux.HandleFunc("GET /page/{n}", func(w http.ResponseWriter, r *http.Request) {
+		n, err := strconv.Atoi(r.PathValue("n"))
+		if err != nil || n < 0 || n >= len(s.Rec.Pages) {
+			http.NotFound(w, r)
+			return
+		}
+		s.page(w, n)
+	})
+}
+
+var scriptRe = regexp.MustCompile(`(?is)<script\b[^>]*>.*?</script>`)
+
+const advanceJS = `<script>(function(){
+var next = %q;
+if (!next) return;
+function go(e){ e.preventDefault(); e.stopPropagation(); location.href = next; }
+document.addEventListener("click", function(e){
+  if (e.target.closest && e.target.closest("input,select,textarea,label,option")) return;
+  go(e);
+}, true);
+document.addEventListener("submit", go, true);
+})();</script>`
+
+func (s *Server) page(w http.ResponseWriter, n int) {
+	p := s.Rec.Pages[n]
+	b, err := os.ReadFile(filepath.Join(s.Dir, p.File))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	next := ""
+	if n+1 < len(s.Rec.Pages) {
+		next = "/page/" + strconv.Itoa(n+1)
+	}
+	html := scriptRe.ReplaceAllString(string(b), "")
+	js := fmt.Sprintf(advanceJS, next)
+	if i := strings.LastIndex(strings.ToLower(html), "</body>"); i >= 0 {
+		html = html[:i] + js + html[i:]
+	} else {
+		html += js
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Replay-Label", p.Label)
+	_, _ = w.Write([]byte(html))
+}
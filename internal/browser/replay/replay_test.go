@@ -0,0 +1,104 @@
+package replay
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const flowDir = "testdata/flow"
+
+func TestLoad(t *testing.T) {
+	r, err := Load(flowDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.BaseURL != "https://termine.example.org/" || len(r.Selectors) != 1 || r.Reused {
+		t.Fatalf("recording = %+v", r)
+	}
+	var labels []string
+	for i, p := range r.Pages {
+		if p.Seq != i {
+			t.Errorf("page %d has seq %d", i, p.Seq)
+		}
+		labels = append(labels, p.Label)
+	}
+	if got := strings.Join(labels, ","); got != "start,menu-1,calendar" {
+		t.Fatalf("labels = %s", got)
+	}
+
+	empty := t.TempDir()
+	if err := os.WriteFile(filepath.Join(empty, ManifestFile), []byte(`{"pages":[]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(empty); err == nil {
+		t.Error("loaded a recording without pages")
+	}
+	if _, err := Load(t.TempDir()); err == nil {
+		t.Error("loaded a directory without manifest")
+	}
+}
+
+func TestServer(t *testing.T) {
+	srv, err := NewServer(flowDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	srv.Register(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	tests := []struct {
+		path, label, next string
+		contains          string
+	}{
+		{path: "/", label: "start", next: `"/page/1"`, contains: `id="zulassung"`},
+		{path: "/page/1", label: "menu-1", next: `"/page/2"`, contains: "Termin buchen"},
+		{path: "/page/2", label: "calendar", next: `""`, contains: "2025-10-21T09:30:00+02:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			res, err := http.Get(ts.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, _ := io.ReadAll(res.Body)
+			res.Body.Close()
+			body := string(b)
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("status %d", res.StatusCode)
+			}
+			if got := res.Header.Get("X-Replay-Label"); got != tt.label {
+				t.Errorf("label = %q, want %q", got, tt.label)
+			}
+			if !strings.Contains(body, tt.contains) {
+				t.Errorf("page lacks %q", tt.contains)
+			}
+			if !strings.Contains(body, "var next = "+tt.next) {
+				t.Errorf("next page is not %s:\n%s", tt.next, body)
+			}
+			if strings.Contains(body, `document.title = "live"`) {
+				t.Error("site script not removed")
+			}
+			if strings.Count(body, "<script>") != 1 {
+				t.Errorf("want only the advance script:\n%s", body)
+			}
+		})
+	}
+
+	for _, path := range []string{"/page/3", "/page/-1", "/page/x"} {
+		res, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", path, res.StatusCode)
+		}
+	}
+}
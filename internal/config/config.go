@@ -1,6 +1,14 @@
 package config
 
-import "os"
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// minAdaptiveSec keeps adaptive polling from hammering the site.
+const minAdaptiveSec = 5
 
 type Config struct {
 	MenuPath string
@@ -9,9 +17,79 @@ type Config struct {
 	Headless bool
 	PollMin  int
 	PollMax  int
+	Schedule string
+	// WholeSlot only matches slots that end inside the availability window.
+	WholeSlot bool
+
+	StatsPath      string
+	AdaptiveMinSec int
+	AdaptiveMaxSec int
+
+	HistoryPath string
+
+	LogLevel  string
+	LogFormat string
+	LogFile   string
+	// LogUnredacted writes personal data to the log in clear text.
+	LogUnredacted bool
+
+	// ArtifactsDir collects screenshots and HTML of failed browser steps,
+	// keeping the newest ArtifactsKeep.
+	ArtifactsDir  string
+	ArtifactsKeep int
+	// RecordDir records every page of each flow for the replay command.
+	RecordDir  string
+	RecordKeep int
+
+	// ConsentSelectors are extra XPaths of cookie banner buttons.
+	ConsentSelectors []string
+
+	// ProfileDir keeps the Chrome profile between runs; serve mode uses a
+	// subdirectory per watch. ReuseCalendar reloads the calendar between
+	// polls instead of clicking through the menu again.
+	ProfileDir    string
+	ReuseCalendar bool
+
+	// Dashboard keeps a live TUI open while watching. Logs then go to LogFile.
+	Dashboard bool
+
+	// HTTPAddr serves /metrics, /healthz and /readyz when set. Without a
+	// host, e.g. ":9090", it listens on 127.0.0.1 only.
+	HTTPAddr       string
+	HealthStaleSec int
+	// APIToken protects the control API of serve. It is required when
+	// HTTPAddr is reachable from other machines.
+	APIToken string
+
+	// PickMode lets a human choose the slot: "" books automatically, "tui"
+	// asks in the dashboard, "web" on a local page served on HTTPAddr.
+	PickMode       string
+	PickTimeoutSec int
+
+	// CodeTimeoutSec bounds the wait for the emailed verification code.
+	// With IMAPAddr set the code is also read from that mailbox.
+	CodeTimeoutSec int
+	CodePattern    string
+	IMAPAddr       string
+	IMAPUser       string
+	IMAPPassword   string
+	IMAPMailbox    string
+	IMAPFrom       string
+
+	// WebUI serves the browser front end of the control API in serve mode.
+	WebUI bool
+	// StatePath persists the watches of serve mode so they are resumed after
+	// a restart.
+	StatePath string
+	// StatePassphrase or StateKeyFile encrypt the personal data in the state
+	// file.
+	StatePassphrase string
+	StateKeyFile    string
 }
 
-func Load() Config {
+// Load reads the configuration from the environment and rejects values that
+// would make the bot misbehave.
+func Load() (Config, error) {
 	menu := os.Getenv("MENU_PATH")
 	if menu == "" {
 		menu = "configs/menu.json"
@@ -24,12 +102,97 @@ func Load() Config {
 	if base == "" {
 		base = "https://reservation.frontdesksuite.com/pinneberg/Termin/Home/Index?Culture=de&PageId=f3e3da57-3aeb-4f3c-8d22-bb44721210d5&ShouldStartReserveTimeFlow=False&ButtonId=00000000-0000-0000-0000-000000000000"
 	}
-	return Config{
+	httpAddr := os.Getenv("HTTP_ADDR")
+	if httpAddr == "" {
+		httpAddr = os.Getenv("METRICS_ADDR")
+	}
+	if strings.HasPrefix(httpAddr, ":") {
+		httpAddr = "127.0.0.1" + httpAddr
+	}
+	dashboard := os.Getenv("DASHBOARD") == "true"
+	logFile := os.Getenv("LOG_FILE")
+	if logFile == "" && dashboard {
+		logFile = "zulassungsstellebot.log"
+	}
+	cfg := Config{
 		MenuPath: menu,
 		TZ:       tz,
 		BaseURL:  base,
 		Headless: true,
 		PollMin:  45,
 		PollMax:  120,
+		Schedule: os.Getenv("POLL_SCHEDULE"),
+
+		WholeSlot: os.Getenv("WHOLE_SLOT") == "true",
+
+		StatsPath:      os.Getenv("STATS_PATH"),
+		AdaptiveMinSec: envInt("ADAPTIVE_MIN_SEC", 15),
+		AdaptiveMaxSec: envInt("ADAPTIVE_MAX_SEC", 120),
+
+		HistoryPath: os.Getenv("HISTORY_PATH"),
+
+		LogLevel:  os.Getenv("LOG_LEVEL"),
+		LogFormat: os.Getenv("LOG_FORMAT"),
+		LogFile:   logFile,
+
+		LogUnredacted: os.Getenv("LOG_UNREDACTED") == "true",
+
+		ArtifactsDir:  os.Getenv("ARTIFACTS_DIR"),
+		ArtifactsKeep: envInt("ARTIFACTS_KEEP", 20),
+		RecordDir:     os.Getenv("RECORD_DIR"),
+		RecordKeep:    envInt("RECORD_KEEP", 10),
+		Dashboard:     dashboard,
+
+		ConsentSelectors: envList("COOKIE_SELECTORS"),
+
+		ProfileDir:    os.Getenv("BROWSER_PROFILE_DIR"),
+		ReuseCalendar: os.Getenv("REUSE_CALENDAR") == "true",
+
+		HTTPAddr:       httpAddr,
+		HealthStaleSec: envInt("HEALTH_STALE_SEC", 300),
+		APIToken:       os.Getenv("API_TOKEN"),
+
+		PickMode:       os.Getenv("PICK_MODE"),
+		PickTimeoutSec: envInt("PICK_TIMEOUT_SEC", 120),
+
+		CodeTimeoutSec: envInt("CODE_TIMEOUT_SEC", 600),
+		CodePattern:    os.Getenv("CODE_PATTERN"),
+		IMAPAddr:       os.Getenv("IMAP_ADDR"),
+		IMAPUser:       os.Getenv("IMAP_USER"),
+		IMAPPassword:   os.Getenv("IMAP_PASSWORD"),
+		IMAPMailbox:    os.Getenv("IMAP_MAILBOX"),
+		IMAPFrom:       os.Getenv("IMAP_FROM"),
+
+		WebUI:     os.Getenv("WEB_UI") == "true",
+		StatePath: os.Getenv("STATE_PATH"),
+
+		StatePassphrase: os.Getenv("STATE_PASSPHRASE"),
+		StateKeyFile:    os.Getenv("STATE_KEY_FILE"),
+	}
+
+	switch {
+	case cfg.AdaptiveMinSec < minAdaptiveSec:
+		return cfg, fmt.Errorf("ADAPTIVE_MIN_SEC muss mindestens %d sein", minAdaptiveSec)
+	case cfg.AdaptiveMaxSec < cfg.AdaptiveMinSec:
+		return cfg, fmt.Errorf("ADAPTIVE_MAX_SEC (%d) ist kleiner als ADAPTIVE_MIN_SEC (%d)", cfg.AdaptiveMaxSec, cfg.AdaptiveMinSec)
+	}
+	return cfg, nil
+}
+
+// envList splits a ";"-separated variable, XPaths may contain "|" and ",".
+func envList(key string) []string {
+	var out []string
+	for _, s := range strings.Split(os.Getenv(key), ";") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func envInt(key string, def int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return n
 	}
+	return def
 }
@@ -17,5 +17,22 @@ func LoadMenu(path string) (domain.MenuNode, error) {
 	if err := json.Unmarshal(b, &root); err != nil {
 		return domain.MenuNode{}, fmt.Errorf("menu parse: %w", err)
 	}
+	if err := checkFields(root); err != nil {
+		return domain.MenuNode{}, fmt.Errorf("menu: %w", err)
+	}
 	return root, nil
 }
+
+func checkFields(n domain.MenuNode) error {
+	for _, f := range n.Fields {
+		if err := f.Check(); err != nil {
+			return fmt.Errorf("%s: %w", n.Title, err)
+		}
+	}
+	for _, c := range n.Children {
+		if err := checkFields(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
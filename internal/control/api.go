@@ -0,0 +1,245 @@
+package control
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/browser"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/domain"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/watcher"
+)
+
+// WatchInfo is the JSON representation of a watch.
+type WatchInfo struct {
+	ID       string                 `json:"id"`
+	Created  time.Time              `json:"created"`
+	Menu     []string               `json:"menu"`
+	Avail    domain.Availability    `json:"availability"`
+	Paused   bool                   `json:"paused"`
+	Override browser.Locator        `json:"override,omitzero"`
+	Result   Result                 `json:"result"`
+	Error    string                 `json:"error,omitempty"`
+	Status   watcher.StatusSnapshot `json:"status"`
+}
+
+func (w *Watch) Info() WatchInfo {
+	res, err := w.Result()
+	info := WatchInfo{
+		ID:       w.ID,
+		Created:  w.Created,
+		Menu:     w.Request.Menu.Path,
+		Avail:    w.Request.Avail,
+		Override: w.Booking.Override,
+		Paused:   w.ctl.Paused(),
+		Result:   res,
+		Status:   w.status.Snapshot(),
+	}
+	if err != nil {
+		info.Error = err.Error()
+	}
+	return info
+}
+
+// API serves the REST interface of a Manager below /api/.
+type API struct {
+	Manager *Manager
+	// DefaultTZ is used for requests without a timezone.
+	DefaultTZ string
+	// Menu is served on /api/menu if set. Watches can then only be started
+	// for its services.
+	Menu *domain.MenuNode
+	// Token, if set, must be sent as "Authorization: Bearer <Token>" with
+	// every request.
+	Token string
+}
+
+func (a *API) Register(mux *http.ServeMux) {
+	handle := func(pattern string, h http.HandlerFunc) { mux.HandleFunc(pattern, a.authorized(h)) }
+	handle("GET /api/menu", a.menu)
+	handle("GET /api/watches", a.list)
+	handle("POST /api/watches", a.create)
+	handle("GET /api/watches/{id}", a.withWatch(a.get))
+	handle("DELETE /api/watches/{id}", a.withWatch(a.cancel))
+	handle("POST /api/watches/{id}/pause", a.withWatch(a.pause))
+	handle("POST /api/watches/{id}/resume", a.withWatch(a.resume))
+	handle("POST /api/watches/{id}/poll", a.withWatch(a.pollNow))
+	handle("POST /api/watches/{id}/code", a.withWatch(a.code))
+	handle("GET /api/watches/{id}/slots", a.withWatch(a.slots))
+	handle("GET /api/watches/{id}/events", a.withWatch(a.events))
+}
+
+// authorized rejects requests without the bearer token if one is set.
+func (a *API) authorized(h http.HandlerFunc) http.HandlerFunc {
+	if a.Token == "" {
+		return h
+	}
+	want := []byte("Bearer " + a.Token)
+	return func(rw http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			rw.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(rw, http.StatusUnauthorized, "missing or wrong token")
+			return
+		}
+		h(rw, r)
+	}
+}
+
+func (a *API) withWatch(h func(http.ResponseWriter, *http.Request, *Watch)) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		w, ok := a.Manager.Get(r.PathValue("id"))
+		if !ok {
+			writeError(rw, http.StatusNotFound, "watch not found")
+			return
+		}
+		h(rw, r, w)
+	}
+}
+
+func (a *API) menu(rw http.ResponseWriter, r *http.Request) {
+	if a.Menu == nil {
+		writeError(rw, http.StatusNotFound, "no menu configured")
+		return
+	}
+	writeJSON(rw, http.StatusOK, struct {
+		Menu *domain.MenuNode `json:"menu"`
+		TZ   string           `json:"tz"`
+	}{a.Menu, a.DefaultTZ})
+}
+
+func (a *API) list(rw http.ResponseWriter, r *http.Request) {
+	out := []WatchInfo{}
+	for _, w := range a.Manager.List() {
+		out = append(out, w.Info())
+	}
+	writeJSON(rw, http.StatusOK, out)
+}
+
+// create starts a watch for a booking request, optionally with an
+// "override" locator for BookSlot next to it.
+func (a *API) create(rw http.ResponseWriter, r *http.Request) {
+	var body struct {
+		domain.BookingRequest
+		browser.BookingOptions
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(rw, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil {
+		writeError(rw, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	req := body.BookingRequest
+	if req.TZ == "" {
+		req.TZ = a.DefaultTZ
+	}
+	// The selectors and extra fields of the service come from the menu, not
+	// the client.
+	if a.Menu != nil {
+		choice, ok := a.Menu.Choice(req.Menu.Path)
+		if !ok {
+			writeError(rw, http.StatusBadRequest, "menu.path is not a service of the menu")
+			return
+		}
+		req.Menu = choice
+	}
+	if err := Validate(req); err != nil {
+		writeError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+	w, err := a.Manager.Start(req, body.BookingOptions)
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(rw, http.StatusCreated, w.Info())
+}
+
+func (a *API) get(rw http.ResponseWriter, r *http.Request, w *Watch) {
+	writeJSON(rw, http.StatusOK, w.Info())
+}
+
+// cancel stops a running watch. A watch that has already finished is
+// removed instead.
+func (a *API) cancel(rw http.ResponseWriter, r *http.Request, w *Watch) {
+	if res, _ := w.Result(); res != ResultRunning {
+		if err := a.Manager.Remove(w.ID); err != nil {
+			writeError(rw, http.StatusInternalServerError, err.Error())
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Cancel()
+	<-w.Done()
+	writeJSON(rw, http.StatusOK, w.Info())
+}
+
+func (a *API) pause(rw http.ResponseWriter, r *http.Request, w *Watch) {
+	w.ctl.Pause()
+	writeJSON(rw, http.StatusOK, w.Info())
+}
+
+func (a *API) resume(rw http.ResponseWriter, r *http.Request, w *Watch) {
+	w.ctl.Resume()
+	writeJSON(rw, http.StatusOK, w.Info())
+}
+
+func (a *API) pollNow(rw http.ResponseWriter, r *http.Request, w *Watch) {
+	w.ctl.PollNow()
+	writeJSON(rw, http.StatusAccepted, w.Info())
+}
+
+// code submits the emailed verification code: {"code": "123456"}.
+func (a *API) code(rw http.ResponseWriter, r *http.Request, w *Watch) {
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(rw, r.Body, 1<<10)).Decode(&body); err != nil || strings.TrimSpace(body.Code) == "" {
+		writeError(rw, http.StatusBadRequest, "code is required")
+		return
+	}
+	if err := w.SubmitCode(strings.TrimSpace(body.Code)); err != nil {
+		writeError(rw, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(rw, http.StatusAccepted, w.Info())
+}
+
+func (a *API) slots(rw http.ResponseWriter, r *http.Request, w *Watch) {
+	s := w.status.Snapshot()
+	writeJSON(rw, http.StatusOK, struct {
+		LastPoll time.Time            `json:"last_poll,omitzero"`
+		Slots    []watcher.SlotStatus `json:"slots"`
+	}{s.LastPoll, s.Slots})
+}
+
+// events returns the recorded events, optionally only those after ?since=N.
+func (a *API) events(rw http.ResponseWriter, r *http.Request, w *Watch) {
+	since := 0
+	if v := r.URL.Query().Get("since"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			writeError(rw, http.StatusBadRequest, "since must be a number")
+			return
+		}
+		since = n
+	}
+	evs, last := w.Events(since)
+	writeJSON(rw, http.StatusOK, struct {
+		Last   int             `json:"last"`
+		Events []watcher.Event `json:"events"`
+	}{last, evs})
+}
+
+func writeJSON(rw http.ResponseWriter, code int, v any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	_ = json.NewEncoder(rw).Encode(v)
+}
+
+func writeError(rw http.ResponseWriter, code int, msg string) {
+	writeJSON(rw, code, map[string]string{"error": msg})
+}
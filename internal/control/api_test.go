@@ -0,0 +1,88 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/browser"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/domain"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/watcher"
+)
+
+var testMenu = domain.MenuNode{Children: []domain.MenuNode{
+	{Title: "Zulassung", Selector: "#zulassung", Children: []domain.MenuNode{
+		{Title: "Neuzulassung", Selector: "#neu", Fields: []domain.FormField{{Label: "Kennzeichen"}}},
+	}},
+}}
+
+const createBody = `{
+  "name": "Erika Mustermann", "email": "erika@example.org", "phone": "0123",
+  "extra": {"Kennzeichen": "PI-AB 123"},
+  "menu": {"path": %s, "selectors": ["//a[@id='evil']"], "fields": [{"label": "Geburtsort"}]},
+  "availability": {"kind": "recurring", "recurring": {"days": [{"weekday": "DI", "from_hour": 8, "to_hour": 12}]}}
+}`
+
+func TestCreateTakesServiceFromMenu(t *testing.T) {
+	var got domain.MenuChoice
+	mgr := NewManager(context.Background(), watcher.Config{}, func(id string, req domain.BookingRequest) (browser.Driver, error) {
+		got = req.Menu
+		return nil, errors.New("no browser in tests")
+	})
+	mux := http.NewServeMux()
+	(&API{Manager: mgr, DefaultTZ: "Europe/Berlin", Menu: &testMenu}).Register(mux)
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{`["Zulassung", "Neuzulassung"]`, http.StatusInternalServerError},
+		{`["Zulassung"]`, http.StatusBadRequest},
+		{`["Abmeldung"]`, http.StatusBadRequest},
+		{`[]`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		body := strings.Replace(createBody, "%s", tt.path, 1)
+		mux.ServeHTTP(rec, httptest.NewRequest("POST", "/api/watches", strings.NewReader(body)))
+		if rec.Code != tt.code {
+			t.Errorf("path %s: status %d, want %d: %s", tt.path, rec.Code, tt.code, rec.Body)
+		}
+	}
+
+	// Only the first request reached the driver, with the menu's selectors
+	// and fields; the client's were dropped.
+	if want := []string{"#zulassung", "#neu"}; !slices.Equal(got.Selectors, want) {
+		t.Errorf("selectors = %q, want %q", got.Selectors, want)
+	}
+	if len(got.Fields) != 1 || got.Fields[0].Label != "Kennzeichen" {
+		t.Errorf("fields = %+v", got.Fields)
+	}
+}
+
+func TestToken(t *testing.T) {
+	mgr := NewManager(context.Background(), watcher.Config{}, nil)
+	mux := http.NewServeMux()
+	(&API{Manager: mgr, Token: "s3cret"}).Register(mux)
+
+	for auth, code := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"s3cret":        http.StatusUnauthorized,
+		"Bearer s3cret": http.StatusOK,
+	} {
+		r := httptest.NewRequest("GET", "/api/watches", nil)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, r)
+		if rec.Code != code {
+			t.Errorf("Authorization %q: status %d, want %d", auth, rec.Code, code)
+		}
+	}
+}
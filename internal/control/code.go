@@ -0,0 +1,44 @@
+package control
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/watcher"
+)
+
+// ErrNoCodeRequested is returned by SubmitCode while the watch is not
+// waiting for a verification code.
+var ErrNoCodeRequested = errors.New("watch is not waiting for a verification code")
+
+// Code implements watcher.CodeSource: it waits for SubmitCode.
+func (w *Watch) Code(ctx context.Context, r watcher.CodeRequest) (string, error) {
+	ch := make(chan string, 1)
+	w.mu.Lock()
+	w.codeReply = ch
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		w.codeReply = nil
+		w.mu.Unlock()
+	}()
+
+	select {
+	case code := <-ch:
+		return code, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// SubmitCode passes the emailed verification code to the waiting watch.
+func (w *Watch) SubmitCode(code string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.codeReply == nil {
+		return ErrNoCodeRequested
+	}
+	w.codeReply <- code
+	w.codeReply = nil
+	return nil
+}
@@ -0,0 +1,428 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/browser"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/domain"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/watcher"
+)
+
+const maxEvents = 200
+
+type Result string
+
+const (
+	ResultRunning Result = "running"
+	// ResultConfirmed is persisted once the site took the booking, while
+	// the verification code may still be outstanding. Such watches are not
+	// resumed after a restart.
+	ResultConfirmed Result = "confirmed"
+	ResultBooked    Result = "booked"
+	ResultFailed    Result = "failed"
+	ResultCancelled Result = "cancelled"
+)
+
+// DriverFactory opens a new browser driver for the watch id.
+type DriverFactory func(id string, req domain.BookingRequest) (browser.Driver, error)
+
+// Manager runs any number of watches side by side, each with its own driver.
+type Manager struct {
+	ctx       context.Context
+	base      watcher.Config
+	newDriver DriverFactory
+
+	mu      sync.Mutex
+	seq     int
+	watches map[string]*Watch
+	wg      sync.WaitGroup
+
+	state    *State
+	onForget func(id string)
+	statsFor func(req domain.BookingRequest) (*watcher.Stats, error)
+}
+
+// NewManager returns a manager whose watches live until ctx is done. base is
+// the configuration shared by all watches; its Observers receive the events
+// of every watch.
+func NewManager(ctx context.Context, base watcher.Config, newDriver DriverFactory) *Manager {
+	return &Manager{ctx: ctx, base: base, newDriver: newDriver, watches: map[string]*Watch{}}
+}
+
+// Watch is a single watcher run managed by a Manager.
+type Watch struct {
+	ID      string
+	Request domain.BookingRequest
+	Booking browser.BookingOptions
+	Created time.Time
+
+	status  *watcher.Status
+	ctl     *watcher.Control
+	cancel  context.CancelFunc
+	done    chan struct{}
+	persist func(func(*StateEntry))
+
+	mu        sync.Mutex
+	events    []watcher.Event
+	eventSeq  int
+	result    Result
+	err       error
+	cancelled bool
+	codeReply chan string
+}
+
+// Start validates req and starts watching for it in the background. opts
+// are passed to BookSlot.
+func (m *Manager) Start(req domain.BookingRequest, opts browser.BookingOptions) (*Watch, error) {
+	if err := Validate(req); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.seq++
+	id := strconv.Itoa(m.seq)
+	m.mu.Unlock()
+
+	drv, err := m.newDriver(id, req)
+	if err != nil {
+		return nil, fmt.Errorf("driver: %w", err)
+	}
+
+	created := time.Now()
+	if m.state != nil {
+		err := m.state.Put(StateEntry{ID: id, Request: req, Booking: opts, Result: ResultRunning, Created: created})
+		if err != nil {
+			_ = drv.Close(m.ctx)
+			return nil, err
+		}
+	}
+	return m.start(id, req, opts, created, drv), nil
+}
+
+// Restore loads the watches persisted in st, resumes the ones that were
+// still running and keeps st up to date from now on. Confirmed, booked and
+// failed watches are listed but never started again. It must be called before
+// Start.
+func (m *Manager) Restore(st *State) (resumed int, err error) {
+	m.state = st
+	for _, e := range st.Entries() {
+		if n, err := strconv.Atoi(e.ID); err == nil {
+			m.seq = max(m.seq, n)
+		}
+		if e.Result != ResultRunning {
+			m.finished(e)
+			continue
+		}
+		if e.Sealed != "" {
+			return resumed, fmt.Errorf("watch %s: personal data is encrypted, set STATE_PASSPHRASE or STATE_KEY_FILE", e.ID)
+		}
+		drv, err := m.newDriver(e.ID, e.Request)
+		if err != nil {
+			return resumed, fmt.Errorf("watch %s: driver: %w", e.ID, err)
+		}
+		m.start(e.ID, e.Request, e.Booking, e.Created, drv)
+		resumed++
+	}
+	return resumed, nil
+}
+
+// finished adds a watch that ended before the last restart.
+func (m *Manager) finished(e StateEntry) {
+	w := &Watch{
+		ID:      e.ID,
+		Request: e.Request,
+		Booking: e.Booking,
+		Created: e.Created,
+		status:  watcher.NewStatus(),
+		ctl:     watcher.NewControl(),
+		cancel:  func() {},
+		done:    make(chan struct{}),
+		persist: func(func(*StateEntry)) {},
+		result:  e.Result,
+	}
+	if e.Error != "" {
+		w.err = errors.New(e.Error)
+	}
+	if e.Result == ResultConfirmed && w.err == nil {
+		// The process stopped while waiting for the code.
+		w.err = watcher.ErrUnverified
+	}
+	if e.Result == ResultBooked {
+		w.status.OnEvent(watcher.Event{Kind: watcher.EventBooked, At: e.Updated, Slot: e.Booked})
+	}
+	w.status.OnEvent(watcher.Event{Kind: watcher.EventStopped, At: e.Updated})
+	close(w.done)
+
+	m.mu.Lock()
+	m.watches[e.ID] = w
+	m.mu.Unlock()
+}
+
+func (m *Manager) start(id string, req domain.BookingRequest, opts browser.BookingOptions, created time.Time, drv browser.Driver) *Watch {
+	ctx, cancel := context.WithCancel(m.ctx)
+	w := &Watch{
+		ID:      id,
+		Request: req,
+		Booking: opts,
+		Created: created,
+		status:  watcher.NewStatus(),
+		ctl:     watcher.NewControl(),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		persist: m.persist(id),
+		result:  ResultRunning,
+	}
+
+	cfg := m.base
+	cfg.Observers = append([]watcher.Observer{w.status, watcher.ObserverFunc(w.record)}, m.base.Observers...)
+	cfg.Control = w.ctl
+	cfg.Booking = opts
+	// The code can be sent over the API, or come from the base source, e.g.
+	// the mailbox.
+	cfg.Code = watcher.AnyCode(w, m.base.Code)
+	if cfg.Logger != nil {
+		cfg.Logger = cfg.Logger.With("watch", id)
+	}
+	if m.statsFor != nil {
+		st, err := m.statsFor(req)
+		if err != nil && cfg.Logger != nil {
+			cfg.Logger.Error("loading stats failed, adaptive polling off", "err", err)
+		}
+		cfg.Adaptive.Stats = st
+	}
+
+	m.mu.Lock()
+	m.watches[id] = w
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer close(w.done)
+		err := watcher.Run(ctx, drv, cfg, req)
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		switch {
+		case err == nil:
+			w.result = ResultBooked
+		case w.cancelled && errors.Is(err, context.Canceled):
+			w.result = ResultCancelled
+			m.forget(id)
+			return
+		case m.ctx.Err() != nil:
+			// Shutting down: the watch stays running in the state file and is
+			// resumed on the next start.
+			w.result = ResultCancelled
+			return
+		default:
+			w.result = ResultFailed
+			w.err = err
+		}
+		w.persist(func(e *StateEntry) {
+			e.Result = w.result
+			if w.err != nil {
+				e.Error = w.err.Error()
+			}
+		})
+	}()
+	return w
+}
+
+// persist returns a function updating the state entry of a watch.
+func (m *Manager) persist(id string) func(func(*StateEntry)) {
+	return func(fn func(*StateEntry)) {
+		if m.state == nil {
+			return
+		}
+		if err := m.state.Update(id, fn); err != nil && m.base.Logger != nil {
+			m.base.Logger.Error("state update failed", "watch", id, "err", err)
+		}
+	}
+}
+
+// StatsFor makes every watch record its polls into the Stats returned by
+// fn instead of sharing those of the base configuration, whose Min and Max
+// still apply. Stats diff each poll against the previous one, so watches
+// for different services must not share them.
+func (m *Manager) StatsFor(fn func(req domain.BookingRequest) (*watcher.Stats, error)) {
+	m.statsFor = fn
+}
+
+// OnForget registers fn to be called once a watch was cancelled or removed,
+// e.g. to delete its browser profile. Its driver is closed by then.
+func (m *Manager) OnForget(fn func(id string)) { m.onForget = fn }
+
+func (m *Manager) forget(id string) {
+	if m.onForget != nil {
+		m.onForget(id)
+	}
+	if m.state == nil {
+		return
+	}
+	if err := m.state.Delete(id); err != nil && m.base.Logger != nil {
+		m.base.Logger.Error("state update failed", "watch", id, "err", err)
+	}
+}
+
+func (m *Manager) Get(id string) (*Watch, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.watches[id]
+	return w, ok
+}
+
+// List returns all watches, oldest first.
+func (m *Manager) List() []*Watch {
+	m.mu.Lock()
+	out := make([]*Watch, 0, len(m.watches))
+	for _, w := range m.watches {
+		out = append(out, w)
+	}
+	m.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Created.Before(out[j].Created) })
+	return out
+}
+
+// Remove drops a watch that has finished, also from the state file.
+func (m *Manager) Remove(id string) error {
+	w, ok := m.Get(id)
+	if !ok {
+		return nil
+	}
+	if res, _ := w.Result(); res == ResultRunning {
+		return errors.New("watch is still running")
+	}
+	m.mu.Lock()
+	delete(m.watches, id)
+	m.mu.Unlock()
+	if m.onForget != nil {
+		m.onForget(id)
+	}
+	if m.state != nil {
+		return m.state.Delete(id)
+	}
+	return nil
+}
+
+// Wait blocks until all watches have returned.
+func (m *Manager) Wait() { m.wg.Wait() }
+
+func (w *Watch) record(e watcher.Event) {
+	// Persist a booking right away so it is never repeated, even if the
+	// process dies before Run returns, e.g. while waiting for the code.
+	switch e.Kind {
+	case watcher.EventConfirmed:
+		w.persist(func(se *StateEntry) {
+			se.Result = ResultConfirmed
+			se.Booked = e.Slot
+		})
+	case watcher.EventBooked:
+		w.persist(func(se *StateEntry) {
+			se.Result = ResultBooked
+			se.Booked = e.Slot
+		})
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.eventSeq++
+	w.events = append(w.events, e)
+	if len(w.events) > maxEvents {
+		w.events = w.events[len(w.events)-maxEvents:]
+	}
+}
+
+// Events returns the recorded events with a sequence number greater than
+// since, together with the sequence number of the last event.
+func (w *Watch) Events(since int) ([]watcher.Event, int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	first := w.eventSeq - len(w.events) + 1
+	from := max(0, since-first+1)
+	if from > len(w.events) {
+		from = len(w.events)
+	}
+	return append([]watcher.Event{}, w.events[from:]...), w.eventSeq
+}
+
+func (w *Watch) Status() *watcher.Status   { return w.status }
+func (w *Watch) Control() *watcher.Control { return w.ctl }
+func (w *Watch) Done() <-chan struct{}     { return w.done }
+
+// Cancel stops the watch. It does not wait for the watcher to return.
+func (w *Watch) Cancel() {
+	w.mu.Lock()
+	w.cancelled = true
+	w.mu.Unlock()
+	w.cancel()
+}
+
+// Result returns the outcome of the watch and, for ResultFailed, the error.
+func (w *Watch) Result() (Result, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.result, w.err
+}
+
+// Validate checks a request received from outside of the TUI.
+func Validate(req domain.BookingRequest) error {
+	switch {
+	case req.Name == "" || req.Email == "" || req.Phone == "":
+		return errors.New("name, email and phone are required")
+	case len(req.Menu.Selectors) == 0:
+		return errors.New("menu.selectors is required")
+	}
+	if _, err := time.LoadLocation(req.TZ); err != nil {
+		return fmt.Errorf("tz: %w", err)
+	}
+	declared := make(map[string]bool, len(req.Menu.Fields))
+	for _, f := range req.Menu.Fields {
+		declared[f.Label] = true
+		if err := f.Validate(req.Extra[f.Label]); err != nil {
+			return fmt.Errorf("extra: %w", err)
+		}
+	}
+	for label := range req.Extra {
+		if !declared[label] {
+			return fmt.Errorf("extra: %q is not a field of this service", label)
+		}
+	}
+
+	switch req.Avail.Kind {
+	case domain.AvailOneOff:
+		o := req.Avail.OneOff
+		if o == nil {
+			return errors.New("availability.oneoff is required")
+		}
+		if _, err := time.Parse("2006-01-02", o.DateISO); err != nil {
+			return errors.New("availability.oneoff.date must be YYYY-MM-DD")
+		}
+		return validateHours(o.FromHour, o.ToHour)
+	case domain.AvailRecurring:
+		r := req.Avail.Recurring
+		if r == nil || len(r.Days) == 0 {
+			return errors.New("availability.recurring.days is required")
+		}
+		for _, d := range r.Days {
+			if err := validateHours(d.FromHour, d.ToHour); err != nil {
+				return fmt.Errorf("%s: %w", d.Weekday, err)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("availability.kind must be %q or %q", domain.AvailOneOff, domain.AvailRecurring)
+	}
+}
+
+func validateHours(from, to int) error {
+	if from < 0 || from > 23 || to < 1 || to > 24 || to <= from {
+		return fmt.Errorf("invalid hours %d–%d", from, to)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/browser"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/domain"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/watcher"
+)
+
+// TestConfirmedNotResumed makes sure a watch that stopped between the
+// confirmation and the verification code is not polled again after a
+// restart.
+func TestConfirmedNotResumed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	st, err := LoadState(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Put(StateEntry{ID: "1", Result: ResultRunning, Created: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(context.Background(), watcher.Config{}, nil)
+	m.state = st
+
+	slot := time.Date(2025, 10, 21, 9, 30, 0, 0, time.UTC)
+	w := &Watch{ID: "1", persist: m.persist("1")}
+	w.record(watcher.Event{Kind: watcher.EventConfirmed, Slot: slot})
+
+	// The process dies here; the next one loads the state file.
+	st, err = LoadState(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := st.Entries()[0]
+	if e.Result != ResultConfirmed || !e.Booked.Equal(slot) {
+		t.Fatalf("persisted %s, booked %v", e.Result, e.Booked)
+	}
+
+	m = NewManager(context.Background(), watcher.Config{}, func(id string, req domain.BookingRequest) (browser.Driver, error) {
+		t.Fatalf("watch %s resumed", id)
+		return nil, nil
+	})
+	resumed, err := m.Restore(st)
+	if err != nil || resumed != 0 {
+		t.Fatalf("resumed %d, err %v", resumed, err)
+	}
+	got, ok := m.Get("1")
+	if !ok {
+		t.Fatal("watch not listed")
+	}
+	if res, err := got.Result(); res != ResultConfirmed || !errors.Is(err, watcher.ErrUnverified) {
+		t.Fatalf("result %s, err %v", res, err)
+	}
+}
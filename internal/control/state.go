@@ -0,0 +1,166 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/browser"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/domain"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/secret"
+)
+
+// StateEntry is a watch as persisted in the state file.
+type StateEntry struct {
+	ID      string                 `json:"id"`
+	Request domain.BookingRequest  `json:"request"`
+	Booking browser.BookingOptions `json:"booking,omitzero"`
+	Result  Result                 `json:"result"`
+	Error   string                 `json:"error,omitempty"`
+	Created time.Time              `json:"created"`
+	Updated time.Time              `json:"updated"`
+	// Booked is the start of the booked slot.
+	Booked time.Time `json:"booked,omitzero"`
+	// Sealed holds the encrypted personal data of Request in the file. In
+	// memory it is only kept if the state was loaded without the key.
+	Sealed string `json:"sealed,omitempty"`
+}
+
+func (e StateEntry) hasPersonal() bool {
+	return len(e.Request.PersonalData.Values()) > 0
+}
+
+// Forget removes the personal data from e.
+func (e *StateEntry) Forget() {
+	e.Request.PersonalData = domain.PersonalData{}
+	e.Sealed = ""
+}
+
+// State keeps the watches of a Manager in a JSON file so they survive a
+// restart. Every change is written through. With a box the personal data of
+// each request is encrypted in the file.
+type State struct {
+	mu      sync.Mutex
+	path    string
+	box     *secret.Box
+	entries map[string]StateEntry
+}
+
+// LoadState reads the state file at path. box may be nil, entries encrypted
+// before then stay sealed.
+func LoadState(path string, box *secret.Box) (*State, error) {
+	s := &State{path: path, box: box, entries: map[string]StateEntry{}}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("state read: %w", err)
+	}
+	var entries []StateEntry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("state parse: %w", err)
+	}
+	for _, e := range entries {
+		if e.Sealed != "" && box != nil {
+			b, err := box.Open(e.Sealed)
+			if err != nil {
+				return nil, fmt.Errorf("state: watch %s: %w", e.ID, err)
+			}
+			var p domain.PersonalData
+			if err := json.Unmarshal(b, &p); err != nil {
+				return nil, fmt.Errorf("state: watch %s: %w", e.ID, err)
+			}
+			e.Request.PersonalData = p
+			e.Sealed = ""
+		}
+		s.entries[e.ID] = e
+	}
+	return s, nil
+}
+
+// Entries returns all entries, oldest first.
+func (s *State) Entries() []StateEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sorted()
+}
+
+// Put adds or replaces the entry with e.ID.
+func (s *State) Put(e StateEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.Updated = time.Now()
+	s.entries[e.ID] = e
+	return s.save()
+}
+
+// Update changes the entry with id in place. Unknown ids are ignored.
+func (s *State) Update(id string, fn func(*StateEntry)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[id]
+	if !ok {
+		return nil
+	}
+	fn(&e)
+	e.Updated = time.Now()
+	s.entries[id] = e
+	return s.save()
+}
+
+func (s *State) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[id]; !ok {
+		return nil
+	}
+	delete(s.entries, id)
+	return s.save()
+}
+
+func (s *State) sorted() []StateEntry {
+	out := make([]StateEntry, 0, len(s.entries))
+	for _, e := range s.entries {
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Created.Before(out[j].Created) })
+	return out
+}
+
+// save writes the file with mode 0600, it contains personal data.
+func (s *State) save() error {
+	entries := s.sorted()
+	if s.box != nil {
+		for i, e := range entries {
+			if e.Sealed != "" || !e.hasPersonal() {
+				continue
+			}
+			b, err := json.Marshal(e.Request.PersonalData)
+			if err != nil {
+				return err
+			}
+			if entries[i].Sealed, err = s.box.Seal(b); err != nil {
+				return fmt.Errorf("state write: %w", err)
+			}
+			entries[i].Request.PersonalData = domain.PersonalData{}
+		}
+	}
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("state write: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("state write: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,117 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	FieldText     = "text"
+	FieldNumber   = "number"
+	FieldTextarea = "textarea"
+	FieldSelect   = "select"
+	FieldCheckbox = "checkbox"
+)
+
+// FormField is an extra field of the booking form, declared per service in
+// menu.json. Its value is kept in PersonalData.Extra under Label.
+type FormField struct {
+	// Label is the text of the field's label on the site.
+	Label string `json:"label"`
+	// Selector is an XPath for the input; by default the input following
+	// the label is used.
+	Selector string `json:"selector,omitempty"`
+	// Type is one of the Field* constants, FieldText if empty.
+	Type string `json:"type,omitempty"`
+	// Pattern is a regular expression the whole value must match.
+	Pattern string `json:"pattern,omitempty"`
+	// Options are the choices of a FieldSelect.
+	Options  []string `json:"options,omitempty"`
+	Optional bool     `json:"optional,omitempty"`
+	// Hint is shown next to the field, e.g. "z. B. PI-AB 123".
+	Hint string `json:"hint,omitempty"`
+}
+
+// Kind returns Type, defaulting to FieldText.
+func (f FormField) Kind() string {
+	if f.Type == "" {
+		return FieldText
+	}
+	return f.Type
+}
+
+// Check validates the definition itself, for loading menu.json.
+func (f FormField) Check() error {
+	if strings.TrimSpace(f.Label) == "" {
+		return errors.New("field without label")
+	}
+	switch f.Kind() {
+	case FieldText, FieldNumber, FieldTextarea, FieldCheckbox:
+	case FieldSelect:
+		if len(f.Options) == 0 {
+			return fmt.Errorf("field %q: select without options", f.Label)
+		}
+	default:
+		return fmt.Errorf("field %q: unknown type %q", f.Label, f.Type)
+	}
+	if f.Pattern != "" {
+		if _, err := regexp.Compile(f.Pattern); err != nil {
+			return fmt.Errorf("field %q: pattern: %w", f.Label, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks a value entered for f. The messages are shown to the user.
+func (f FormField) Validate(v string) error {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		if f.Optional {
+			return nil
+		}
+		return fmt.Errorf("%s: Pflichtfeld", f.Label)
+	}
+	switch f.Kind() {
+	case FieldNumber:
+		if _, err := strconv.Atoi(v); err != nil {
+			return fmt.Errorf("%s: keine Zahl", f.Label)
+		}
+	case FieldSelect:
+		if !slices.Contains(f.Options, v) {
+			return fmt.Errorf("%s: erlaubt sind %s", f.Label, strings.Join(f.Options, ", "))
+		}
+	case FieldCheckbox:
+		if _, ok := ParseYes(v); !ok {
+			return fmt.Errorf("%s: bitte ja oder nein", f.Label)
+		}
+	}
+	if f.Pattern != "" {
+		re, err := regexp.Compile(`^(?:` + f.Pattern + `)$`)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Label, err)
+		}
+		if !re.MatchString(v) {
+			msg := "ungültiges Format"
+			if f.Hint != "" {
+				msg += " (" + f.Hint + ")"
+			}
+			return fmt.Errorf("%s: %s", f.Label, msg)
+		}
+	}
+	return nil
+}
+
+// ParseYes reads the value of a FieldCheckbox.
+func ParseYes(v string) (yes, ok bool) {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "ja", "j", "yes", "y", "true", "1", "x":
+		return true, true
+	case "nein", "n", "no", "false", "0", "":
+		return false, true
+	}
+	return false, false
+}
@@ -1,5 +1,10 @@
 package domain
 
+import (
+	"maps"
+	"slices"
+)
+
 type AvailabilityKind string
 
 const (
@@ -8,25 +13,25 @@ const (
 )
 
 type OneOff struct {
-	DateISO  string
-	FromHour int
-	ToHour   int
+	DateISO  string `json:"date"`
+	FromHour int    `json:"from_hour"`
+	ToHour   int    `json:"to_hour"`
 }
 
 type DayWindow struct {
-	Weekday  string
-	FromHour int
-	ToHour   int
+	Weekday  string `json:"weekday"`
+	FromHour int    `json:"from_hour"`
+	ToHour   int    `json:"to_hour"`
 }
 
 type Recurring struct {
-	Days []DayWindow
+	Days []DayWindow `json:"days"`
 }
 
 type Availability struct {
-	Kind      AvailabilityKind
-	OneOff    *OneOff
-	Recurring *Recurring
+	Kind      AvailabilityKind `json:"kind"`
+	OneOff    *OneOff          `json:"oneoff,omitempty"`
+	Recurring *Recurring       `json:"recurring,omitempty"`
 }
 
 type MenuNode struct {
@@ -34,18 +39,83 @@ type MenuNode struct {
 	Children []MenuNode `json:"children,omitempty" yaml:"children,omitempty"`
 	Selector string     `json:"selector,omitempty"`
 	Path     []string   `json:"path,omitempty"`
+	// Fields are asked for in addition to name, email and phone when this
+	// leaf is booked.
+	Fields []FormField `json:"fields,omitempty"`
+}
+
+// Choice returns the service reached by following the titles in path, with
+// the selectors along the path and the fields of the leaf. ok is false if
+// path does not end at a leaf of n.
+func (n MenuNode) Choice(path []string) (c MenuChoice, ok bool) {
+	if len(path) == 0 {
+		return MenuChoice{}, false
+	}
+	for _, title := range path {
+		i := slices.IndexFunc(n.Children, func(c MenuNode) bool { return c.Title == title })
+		if i < 0 {
+			return MenuChoice{}, false
+		}
+		n = n.Children[i]
+		if n.Selector != "" {
+			c.Selectors = append(c.Selectors, n.Selector)
+		}
+	}
+	if len(n.Children) > 0 {
+		return MenuChoice{}, false
+	}
+	c.Path = slices.Clone(path)
+	c.Fields = n.Fields
+	return c, true
 }
 
 type MenuChoice struct {
-	Path      []string
-	Selectors []string
+	Path      []string    `json:"path"`
+	Selectors []string    `json:"selectors"`
+	Fields    []FormField `json:"fields,omitempty"`
+}
+
+// PersonalData is what the booking form asks for.
+type PersonalData struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Phone string `json:"phone"`
+	// Extra holds further fields some services require, e.g. the license
+	// plate or the birth date, keyed by the label of the field on the site.
+	Extra map[string]string `json:"extra,omitempty"`
+}
+
+// Values returns all non-empty values of p.
+func (p PersonalData) Values() []string {
+	var out []string
+	for _, v := range append([]string{p.Name, p.Email, p.Phone}, slices.Sorted(maps.Values(p.Extra))...) {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
 }
 
 type BookingRequest struct {
-	Name  string
-	Email string
-	Phone string
-	Menu  MenuChoice
-	Avail Availability
-	TZ    string
+	PersonalData
+	Menu  MenuChoice   `json:"menu"`
+	Avail Availability `json:"availability"`
+	TZ    string       `json:"tz"`
+}
+
+// Redacted returns a copy of r without personal data, for logging.
+func (r BookingRequest) Redacted() BookingRequest {
+	for _, f := range []*string{&r.Name, &r.Email, &r.Phone} {
+		if *f != "" {
+			*f = "[redacted]"
+		}
+	}
+	if r.Extra != nil {
+		extra := make(map[string]string, len(r.Extra))
+		for k := range r.Extra {
+			extra[k] = "[redacted]"
+		}
+		r.Extra = extra
+	}
+	return r
 }
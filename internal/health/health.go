@@ -0,0 +1,102 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/watcher"
+)
+
+// Prober checks whether the browser behind a driver still responds.
+type Prober interface {
+	Alive(ctx context.Context) error
+}
+
+// Checker serves /healthz and /readyz for a single watcher.
+type Checker struct {
+	Status *watcher.Status
+	// Browser is optional; without it the browser is assumed to be alive.
+	Browser Prober
+	// Stale is how long the watcher may stay in a working state, or oversleep
+	// its next poll, before it is considered wedged.
+	Stale time.Duration
+}
+
+type report struct {
+	Status  string                 `json:"status"`
+	Reason  string                 `json:"reason,omitempty"`
+	Browser string                 `json:"browser"`
+	Watcher watcher.StatusSnapshot `json:"watcher"`
+}
+
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", c.healthz)
+	mux.HandleFunc("GET /readyz", c.readyz)
+}
+
+// healthz fails when the browser is gone or polling has stalled, which is the
+// signal for a supervisor to restart the process.
+func (c *Checker) healthz(w http.ResponseWriter, r *http.Request) {
+	rep, browserErr := c.report(r.Context())
+	switch {
+	case browserErr != nil:
+		rep.Reason = "browser not responding"
+	case c.stalled(rep.Watcher):
+		rep.Reason = "polling stalled in state " + string(rep.Watcher.State)
+	case rep.Watcher.State == watcher.StateStopped:
+		rep.Reason = "watcher stopped"
+	}
+	write(w, rep)
+}
+
+// readyz succeeds once the watcher has listed slots at least once.
+func (c *Checker) readyz(w http.ResponseWriter, r *http.Request) {
+	rep, browserErr := c.report(r.Context())
+	switch {
+	case browserErr != nil:
+		rep.Reason = "browser not responding"
+	case rep.Watcher.LastPoll.IsZero():
+		rep.Reason = "no successful poll yet"
+	}
+	write(w, rep)
+}
+
+func (c *Checker) report(ctx context.Context) (report, error) {
+	rep := report{Browser: "alive", Watcher: c.Status.Snapshot()}
+	var err error
+	if c.Browser != nil {
+		if err = c.Browser.Alive(ctx); err != nil {
+			rep.Browser = err.Error()
+		}
+	}
+	return rep, err
+}
+
+func (c *Checker) stalled(s watcher.StatusSnapshot) bool {
+	stale := c.Stale
+	if stale <= 0 {
+		stale = 5 * time.Minute
+	}
+	now := time.Now()
+	switch s.State {
+	case watcher.StateNavigating, watcher.StateListing, watcher.StateBooking, watcher.StateStarting:
+		return now.Sub(s.Since) > stale
+	case watcher.StateSleeping, watcher.StatePaused:
+		return !s.NextPoll.IsZero() && now.Sub(s.NextPoll) > stale
+	}
+	return false
+}
+
+func write(w http.ResponseWriter, rep report) {
+	code := http.StatusOK
+	rep.Status = "ok"
+	if rep.Reason != "" {
+		code = http.StatusServiceUnavailable
+		rep.Status = "unavailable"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(rep)
+}
@@ -0,0 +1,189 @@
+package history
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxGap separates two watch sessions; slots are not tracked across it, since
+// nobody was looking while the bot was not running.
+const maxGap = 30 * time.Minute
+
+type DayEarliest struct {
+	Day      string
+	Earliest time.Time
+}
+
+type ServiceReport struct {
+	Service   string
+	Snapshots int
+	From, To  time.Time
+
+	// Lifetimes holds how long each slot was listed before it disappeared.
+	// Slots that vanished because their start time passed are not counted.
+	Lifetimes []time.Duration
+	// Releases counts newly appeared slots per hour of the day.
+	Releases [24]int
+	Earliest []DayEarliest
+	// SlotLength is the most common length of a slot, 0 if unknown.
+	SlotLength time.Duration
+}
+
+func (r ServiceReport) MedianLifetime() time.Duration {
+	if len(r.Lifetimes) == 0 {
+		return 0
+	}
+	l := slices.Clone(r.Lifetimes)
+	slices.Sort(l)
+	if len(l)%2 == 1 {
+		return l[len(l)/2]
+	}
+	return (l[len(l)/2-1] + l[len(l)/2]) / 2
+}
+
+// BusiestHours returns up to n hours of the day with the most releases.
+func (r ServiceReport) BusiestHours(n int) []int {
+	var hours []int
+	for h, c := range r.Releases {
+		if c > 0 {
+			hours = append(hours, h)
+		}
+	}
+	sort.SliceStable(hours, func(i, j int) bool { return r.Releases[hours[i]] > r.Releases[hours[j]] })
+	if len(hours) > n {
+		hours = hours[:n]
+	}
+	return hours
+}
+
+// Analyze groups snapshots by menu path and computes per-service statistics.
+// Times of day are evaluated in loc.
+func Analyze(snaps []Snapshot, loc *time.Location) []ServiceReport {
+	bySvc := map[string][]Snapshot{}
+	for _, s := range snaps {
+		key := strings.Join(s.Menu, " > ")
+		bySvc[key] = append(bySvc[key], s)
+	}
+
+	var out []ServiceReport
+	for svc, list := range bySvc {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].At.Before(list[j].At) })
+		out = append(out, analyzeService(svc, list, loc))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Service < out[j].Service })
+	return out
+}
+
+func analyzeService(svc string, list []Snapshot, loc *time.Location) ServiceReport {
+	r := ServiceReport{Service: svc, Snapshots: len(list)}
+	if len(list) == 0 {
+		return r
+	}
+	r.From, r.To = list[0].At, list[len(list)-1].At
+
+	firstSeen := map[int64]time.Time{}
+	earliest := map[string]time.Time{}
+	lengths := map[int]int{}
+	var prev *Snapshot
+	for i := range list {
+		cur := &list[i]
+		fresh := prev == nil || cur.At.Sub(prev.At) > maxGap
+		if fresh {
+			clear(firstSeen)
+		}
+
+		present := make(map[int64]bool, len(cur.Slots))
+		day := cur.At.In(loc).Format("2006-01-02")
+		for j, t := range cur.Slots {
+			present[t.Unix()] = true
+			if j < len(cur.Minutes) && cur.Minutes[j] > 0 {
+				lengths[cur.Minutes[j]]++
+			}
+			if _, ok := firstSeen[t.Unix()]; !ok {
+				firstSeen[t.Unix()] = cur.At
+				if !fresh {
+					r.Releases[cur.At.In(loc).Hour()]++
+				}
+			}
+			if e, ok := earliest[day]; !ok || t.Before(e) {
+				earliest[day] = t
+			}
+		}
+
+		for k, seen := range firstSeen {
+			if present[k] {
+				continue
+			}
+			if time.Unix(k, 0).After(cur.At) {
+				r.Lifetimes = append(r.Lifetimes, cur.At.Sub(seen))
+			}
+			delete(firstSeen, k)
+		}
+		prev = cur
+	}
+
+	best := 0
+	for m, n := range lengths {
+		if n > lengths[best] || (n == lengths[best] && m < best) {
+			best = m
+		}
+	}
+	r.SlotLength = time.Duration(best) * time.Minute
+
+	for day, t := range earliest {
+		r.Earliest = append(r.Earliest, DayEarliest{Day: day, Earliest: t})
+	}
+	sort.Slice(r.Earliest, func(i, j int) bool { return r.Earliest[i].Day < r.Earliest[j].Day })
+	return r
+}
+
+// WriteReport prints the reports in a human readable form.
+func WriteReport(w io.Writer, reps []ServiceReport, loc *time.Location) {
+	if len(reps) == 0 {
+		fmt.Fprintln(w, "Keine Daten vorhanden.")
+		return
+	}
+	for i, r := range reps {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "📊 %s\n", r.Service)
+		fmt.Fprintf(w, "Zeitraum:  %s – %s (%d Abfragen)\n",
+			r.From.In(loc).Format("02.01.2006 15:04"), r.To.In(loc).Format("02.01.2006 15:04"), r.Snapshots)
+
+		if r.SlotLength > 0 {
+			fmt.Fprintf(w, "Terminlänge: %d Minuten\n", int(r.SlotLength/time.Minute))
+		}
+		if len(r.Lifetimes) == 0 {
+			fmt.Fprintln(w, "Median bis vergeben: (keine Daten)")
+		} else {
+			fmt.Fprintf(w, "Median bis vergeben: %s (%d Slots)\n", r.MedianLifetime().Round(time.Second), len(r.Lifetimes))
+		}
+
+		hours := r.BusiestHours(3)
+		if len(hours) == 0 {
+			fmt.Fprintln(w, "Freigaben: (keine neuen Slots beobachtet)")
+		} else {
+			parts := make([]string, 0, len(hours))
+			for _, h := range hours {
+				parts = append(parts, fmt.Sprintf("%02d–%02d Uhr (%d)", h, h+1, r.Releases[h]))
+			}
+			fmt.Fprintf(w, "Häufigste Freigaben: %s\n", strings.Join(parts, ", "))
+		}
+
+		if len(r.Earliest) > 0 {
+			fmt.Fprintln(w, "Frühester freier Termin:")
+			from := max(0, len(r.Earliest)-14)
+			for _, e := range r.Earliest[from:] {
+				day, _ := time.ParseInLocation("2006-01-02", e.Day, loc)
+				lead := e.Earliest.In(loc).Sub(day).Hours() / 24
+				fmt.Fprintf(w, "  %s  →  %s (%.0f Tage Vorlauf)\n",
+					day.Format("02.01.2006"), e.Earliest.In(loc).Format("02.01.2006 15:04"), lead)
+			}
+		}
+	}
+}
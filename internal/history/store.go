@@ -0,0 +1,83 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Snapshot is the result of a single ListSlots call for one service.
+type Snapshot struct {
+	At    time.Time   `json:"at"`
+	Menu  []string    `json:"menu"`
+	Slots []time.Time `json:"slots"`
+	// Minutes holds the length of each slot, 0 if the site showed no end.
+	// It is omitted if no length is known at all.
+	Minutes []int `json:"minutes,omitempty"`
+}
+
+// Store appends snapshots to a JSONL file, one snapshot per line.
+type Store struct {
+	mu   sync.Mutex
+	path string
+}
+
+func Open(path string) *Store { return &Store{path: path} }
+
+func (s *Store) Append(snap Snapshot) error {
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("history write: %w", err)
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("history write: %w", err)
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("history write: %w", err)
+	}
+	return f.Close()
+}
+
+// Load reads all snapshots from path. A missing file yields no snapshots.
+func Load(path string) ([]Snapshot, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("history read: %w", err)
+	}
+	defer f.Close()
+
+	var out []Snapshot
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var snap Snapshot
+		if err := json.Unmarshal(sc.Bytes(), &snap); err != nil {
+			return nil, fmt.Errorf("history parse line %d: %w", line, err)
+		}
+		out = append(out, snap)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("history read: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,43 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// New builds the application logger. format is "text" or "json"; the level
+// can be changed at runtime through lv.
+func New(w io.Writer, format string, lv *slog.LevelVar) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: lv}
+	switch strings.ToLower(format) {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+}
+
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", s)
+	}
+	return l, nil
+}
+
+// Toggle switches lv between debug and base, returning the new level.
+func Toggle(lv *slog.LevelVar, base slog.Level) slog.Level {
+	if lv.Level() == slog.LevelDebug {
+		lv.Set(base)
+	} else {
+		lv.Set(slog.LevelDebug)
+	}
+	return lv.Level()
+}
@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// piiKeys are attribute keys whose values are always masked.
+var piiKeys = map[string]bool{
+	"name":    true,
+	"email":   true,
+	"phone":   true,
+	"telefon": true,
+}
+
+var emailRe = regexp.MustCompile(`[\w.+-]+@[\w-]+(\.[\w-]+)+`)
+
+// Redact returns a logger that masks personal data: attributes with keys
+// like "name" or "email", email addresses anywhere in messages and string
+// values, and every occurrence of the given values. Redacting an already
+// redacted logger adds to its values.
+func Redact(l *slog.Logger, values ...string) *slog.Logger {
+	var vals []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); len(v) >= 3 {
+			vals = append(vals, v)
+		}
+	}
+	h := l.Handler()
+	if r, ok := h.(*redactHandler); ok {
+		if len(vals) == 0 {
+			return l
+		}
+		return slog.New(&redactHandler{h: r.h, values: append(slices.Clip(r.values), vals...)})
+	}
+	return slog.New(&redactHandler{h: h, values: vals})
+}
+
+type redactHandler struct {
+	h      slog.Handler
+	values []string
+}
+
+func (r *redactHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return r.h.Enabled(ctx, l)
+}
+
+func (r *redactHandler) Handle(ctx context.Context, rec slog.Record) error {
+	out := slog.NewRecord(rec.Time, rec.Level, r.scrub(rec.Message), rec.PC)
+	rec.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(r.attr(a))
+		return true
+	})
+	return r.h.Handle(ctx, out)
+}
+
+func (r *redactHandler) WithAttrs(as []slog.Attr) slog.Handler {
+	red := make([]slog.Attr, len(as))
+	for i, a := range as {
+		red[i] = r.attr(a)
+	}
+	return &redactHandler{h: r.h.WithAttrs(red), values: r.values}
+}
+
+func (r *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{h: r.h.WithGroup(name), values: r.values}
+}
+
+func (r *redactHandler) attr(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch {
+	case v.Kind() == slog.KindGroup:
+		as := v.Group()
+		red := make([]slog.Attr, len(as))
+		for i, ga := range as {
+			red[i] = r.attr(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(red...)}
+	case piiKeys[strings.ToLower(a.Key)] && v.Kind() == slog.KindString:
+		return slog.String(a.Key, Mask(v.String()))
+	case v.Kind() == slog.KindString:
+		return slog.String(a.Key, r.scrub(v.String()))
+	case v.Kind() == slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			return slog.String(a.Key, r.scrub(err.Error()))
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
+
+func (r *redactHandler) scrub(s string) string {
+	for _, v := range r.values {
+		s = strings.ReplaceAll(s, v, Mask(v))
+	}
+	return emailRe.ReplaceAllStringFunc(s, Mask)
+}
+
+// Mask keeps the first character of s, and the domain of an email address.
+func Mask(s string) string {
+	if s == "" {
+		return ""
+	}
+	_, n := utf8.DecodeRuneInString(s)
+	if i := strings.LastIndexByte(s, '@'); i > 0 {
+		return s[:n] + "***" + s[i:]
+	}
+	return s[:n] + "***"
+}
@@ -0,0 +1,324 @@
+// Package mailcode reads the verification code of a booking from an IMAP
+// mailbox, so the bot can confirm bookings without a human.
+package mailcode
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/watcher"
+)
+
+// DefaultPattern matches four to eight digits shortly after the word
+// "Code", so dates and postal codes in the mail are not taken.
+var DefaultPattern = regexp.MustCompile(`(?i)code\D{0,40}?\b(\d{4,8})\b`)
+
+// IMAP polls a mailbox over TLS for a mail to the booking's address received
+// after the booking form was submitted and takes the code from it. It
+// implements watcher.CodeSource; every call uses its own connection.
+type IMAP struct {
+	// Addr is host:port of the IMAP server, port 993 if omitted.
+	Addr     string
+	User     string
+	Password string
+	// Mailbox defaults to INBOX.
+	Mailbox string
+	// From, if set, must be contained in the sender address.
+	From string
+	// Pattern finds the code in the mail text; its first group is the
+	// code. DefaultPattern if nil.
+	Pattern  *regexp.Regexp
+	Interval time.Duration
+	Logger   *slog.Logger
+}
+
+func (m *IMAP) Code(ctx context.Context, r watcher.CodeRequest) (string, error) {
+	log := m.Logger
+	if log == nil {
+		log = slog.Default()
+	}
+	interval := m.Interval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	seen := map[string]bool{}
+	for {
+		code, err := m.poll(ctx, r, seen)
+		switch {
+		case err != nil:
+			log.Warn("reading mailbox failed", "err", err)
+		case code != "":
+			log.Info("verification code read from mailbox")
+			return code, nil
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// poll looks once at the mails of today that were not seen yet. A mail only
+// counts as seen once it was fetched.
+func (m *IMAP) poll(ctx context.Context, r watcher.CodeRequest, seen map[string]bool) (string, error) {
+	c, err := m.dial(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer c.close()
+
+	if _, err := c.cmd("LOGIN " + quote(m.User) + " " + quote(m.Password)); err != nil {
+		return "", fmt.Errorf("login: %w", err)
+	}
+	box := m.Mailbox
+	if box == "" {
+		box = "INBOX"
+	}
+	if _, err := c.cmd("EXAMINE " + quote(box)); err != nil {
+		return "", fmt.Errorf("examine %s: %w", box, err)
+	}
+	// SINCE has day granularity, the Date header decides below.
+	res, err := c.cmd("UID SEARCH SINCE " + r.Since.Add(-24*time.Hour).Format("2-Jan-2006"))
+	if err != nil {
+		return "", fmt.Errorf("search: %w", err)
+	}
+	var uids []string
+	for _, line := range res.lines {
+		if rest, ok := strings.CutPrefix(line, "* SEARCH"); ok {
+			uids = append(uids, strings.Fields(rest)...)
+		}
+	}
+
+	// Newest first
+	for i := len(uids) - 1; i >= 0; i-- {
+		uid := uids[i]
+		if seen[uid] {
+			continue
+		}
+		res, err := c.cmd("UID FETCH " + uid + " BODY.PEEK[]")
+		if err != nil {
+			return "", fmt.Errorf("fetch: %w", err)
+		}
+		seen[uid] = true
+		for _, lit := range res.literals {
+			if code := m.codeOf(lit, r); code != "" {
+				return code, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// codeOf returns the code in a raw mail to r.Email received after r.Since.
+func (m *IMAP) codeOf(raw []byte, r watcher.CodeRequest) string {
+	msg, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		return ""
+	}
+	// Allow for clock skew between the mail server and us.
+	if d, err := msg.Header.Date(); err == nil && d.Before(r.Since.Add(-time.Minute)) {
+		return ""
+	}
+	// Watches sharing the mailbox must not take each other's codes.
+	if r.Email != "" && !sentTo(msg.Header, r.Email) {
+		return ""
+	}
+	if m.From != "" && !strings.Contains(strings.ToLower(msg.Header.Get("From")), strings.ToLower(m.From)) {
+		return ""
+	}
+	re := m.Pattern
+	if re == nil {
+		re = DefaultPattern
+	}
+	text := msg.Header.Get("Subject") + "\n" + bodyText(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	match := re.FindStringSubmatch(text)
+	switch {
+	case match == nil:
+		return ""
+	case len(match) > 1:
+		return match[1]
+	default:
+		return match[0]
+	}
+}
+
+// sentTo reports whether a recipient header of the mail names addr.
+func sentTo(h mail.Header, addr string) bool {
+	addr = strings.ToLower(strings.TrimSpace(addr))
+	for _, key := range []string{"To", "Cc", "Delivered-To", "X-Original-To"} {
+		for _, v := range h[textproto.CanonicalMIMEHeaderKey(key)] {
+			list, err := mail.ParseAddressList(v)
+			if err != nil {
+				// Unparsable list, compare the raw value.
+				if strings.Contains(strings.ToLower(v), addr) {
+					return true
+				}
+				continue
+			}
+			for _, a := range list {
+				if strings.ToLower(a.Address) == addr {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+var reTags = regexp.MustCompile(`<[^>]*>`)
+
+// bodyText returns the decoded text parts of a mail body.
+func bodyText(ctype, encoding string, body io.Reader) string {
+	mt, params, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		mt = "text/plain"
+	}
+	if strings.HasPrefix(mt, "multipart/") {
+		var parts []string
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextRawPart()
+			if err != nil {
+				break
+			}
+			parts = append(parts, bodyText(p.Header.Get("Content-Type"), p.Header.Get("Content-Transfer-Encoding"), p))
+		}
+		return strings.Join(parts, "\n")
+	}
+	if !strings.HasPrefix(mt, "text/") {
+		return ""
+	}
+	switch strings.ToLower(encoding) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, &stripNewlines{r: body})
+	}
+	b, _ := io.ReadAll(io.LimitReader(body, 1<<20))
+	s := string(b)
+	if mt == "text/html" {
+		s = reTags.ReplaceAllString(s, " ")
+	}
+	return s
+}
+
+type stripNewlines struct{ r io.Reader }
+
+func (s *stripNewlines) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	j := 0
+	for _, c := range p[:n] {
+		if c != '\r' && c != '\n' {
+			p[j] = c
+			j++
+		}
+	}
+	return j, err
+}
+
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// conn is the minimal IMAP4rev1 client needed here.
+type conn struct {
+	nc  net.Conn
+	r   *bufio.Reader
+	tag int
+}
+
+type response struct {
+	lines    []string
+	literals [][]byte
+}
+
+const ioTimeout = 30 * time.Second
+
+// maxLiteral bounds the part of a literal kept in memory, like bodyText
+// bounds a body; the rest is read and dropped.
+const maxLiteral = 1 << 20
+
+func (m *IMAP) dial(ctx context.Context) (*conn, error) {
+	addr := m.Addr
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "993")
+	}
+	d := tls.Dialer{NetDialer: &net.Dialer{Timeout: ioTimeout}}
+	nc, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{nc: nc, r: bufio.NewReader(nc)}
+	_ = nc.SetDeadline(time.Now().Add(ioTimeout))
+	greeting, err := c.r.ReadString('\n')
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		nc.Close()
+		return nil, fmt.Errorf("unexpected greeting %q", strings.TrimSpace(greeting))
+	}
+	return c, nil
+}
+
+func (c *conn) close() {
+	_, _ = c.cmd("LOGOUT")
+	c.nc.Close()
+}
+
+// cmd sends a command and reads the untagged lines and literals up to its
+// tagged completion.
+func (c *conn) cmd(command string) (response, error) {
+	c.tag++
+	tag := "z" + strconv.Itoa(c.tag)
+	_ = c.nc.SetDeadline(time.Now().Add(ioTimeout))
+	if _, err := io.WriteString(c.nc, tag+" "+command+"\r\n"); err != nil {
+		return response{}, err
+	}
+	var res response
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return res, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		// A literal {n} follows the line.
+		if i := strings.LastIndexByte(line, '{'); i >= 0 && strings.HasSuffix(line, "}") {
+			if n, err := strconv.ParseInt(line[i+1:len(line)-1], 10, 64); err == nil && n >= 0 {
+				lit := make([]byte, min(n, maxLiteral))
+				if _, err := io.ReadFull(c.r, lit); err != nil {
+					return res, err
+				}
+				if _, err := io.CopyN(io.Discard, c.r, n-int64(len(lit))); err != nil {
+					return res, err
+				}
+				res.literals = append(res.literals, lit)
+			}
+		}
+		if rest, ok := strings.CutPrefix(line, tag+" "); ok {
+			if strings.HasPrefix(rest, "OK") {
+				return res, nil
+			}
+			return res, errors.New(rest)
+		}
+		res.lines = append(res.lines, line)
+	}
+}
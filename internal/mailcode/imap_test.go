@@ -0,0 +1,209 @@
+package mailcode
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/watcher"
+)
+
+func TestCodeOf(t *testing.T) {
+	since := time.Date(2025, 10, 21, 9, 0, 0, 0, time.UTC)
+	req := watcher.CodeRequest{Email: "erika@example.org", Since: since}
+
+	// mail builds a raw mail; headers are given without Date and To, which
+	// default to after since and to the request's address.
+	mail := func(headers, body string) []byte {
+		h := "Date: Tue, 21 Oct 2025 09:05:00 +0000\r\nTo: Erika <Erika@Example.org>\r\nFrom: Termine <noreply@frontdesksuite.com>\r\n"
+		for _, line := range strings.Split(headers, "\n") {
+			if line == "" {
+				continue
+			}
+			key := line[:strings.IndexByte(line, ':')+1]
+			if i := strings.Index(h, key); i >= 0 {
+				h = h[:i] + h[i+strings.Index(h[i:], "\r\n")+2:]
+			}
+			h += line + "\r\n"
+		}
+		return []byte(h + "\r\n" + body)
+	}
+	b64 := base64.StdEncoding.EncodeToString([]byte("Hallo,\nIhr Bestätigungscode lautet 482913.\n"))
+	b64 = b64[:20] + "\r\n" + b64[20:]
+
+	tests := []struct {
+		name string
+		raw  []byte
+		from string
+		re   *regexp.Regexp
+		want string
+	}{
+		{name: "plain", raw: mail("", "Ihr Code: 123456\r\n"), want: "123456"},
+		{name: "subject", raw: mail("Subject: Code 7788 für Ihre Buchung", "Hallo"), want: "7788"},
+		{name: "date and postal code ignored", raw: mail("", "Am 21.10.2025 in 25421 Pinneberg.\r\nCode: 9911\r\n"), want: "9911"},
+		{name: "no code", raw: mail("", "Vielen Dank für Ihre Buchung.\r\n"), want: ""},
+		{name: "older than since", raw: mail("Date: Tue, 21 Oct 2025 08:50:00 +0000", "Code: 123456"), want: ""},
+		{name: "clock skew", raw: mail("Date: Tue, 21 Oct 2025 08:59:30 +0000", "Code: 123456"), want: "123456"},
+		{name: "other recipient", raw: mail("To: max@example.org", "Code: 123456"), want: ""},
+		{name: "cc", raw: mail("To: max@example.org\nCc: erika@example.org", "Code: 123456"), want: "123456"},
+		{name: "delivered-to", raw: mail("To: undisclosed-recipients:;\nDelivered-To: erika@example.org", "Code: 123456"), want: "123456"},
+		{name: "from matches", raw: mail("", "Code: 123456"), from: "FrontDeskSuite", want: "123456"},
+		{name: "from differs", raw: mail("From: spam@example.com", "Code: 123456"), from: "frontdesksuite", want: ""},
+		{
+			name: "quoted-printable",
+			raw:  mail("Content-Type: text/plain; charset=utf-8\nContent-Transfer-Encoding: quoted-printable", "Ihr Best=C3=A4tigungs=\r\ncode: 5566\r\n"),
+			want: "5566",
+		},
+		{
+			name: "base64",
+			raw:  mail("Content-Type: text/plain; charset=utf-8\nContent-Transfer-Encoding: base64", b64),
+			want: "482913",
+		},
+		{
+			name: "html",
+			raw:  mail("Content-Type: text/html", "<p>Ihr <b>Code</b>:</p><p><strong>4321</strong></p>"),
+			want: "4321",
+		},
+		{
+			name: "multipart",
+			raw: mail("MIME-Version: 1.0\nContent-Type: multipart/alternative; boundary=\"b1\"",
+				"--b1\r\nContent-Type: image/png\r\nContent-Transfer-Encoding: base64\r\n\r\nQ29kZTogMTExMQ==\r\n"+
+					"--b1\r\nContent-Type: text/html; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n<div>Code=3A <b>2468</b></div>\r\n"+
+					"--b1--\r\n"),
+			want: "2468",
+		},
+		{name: "pattern", raw: mail("", "PIN 12-34"), re: regexp.MustCompile(`PIN (\d+-\d+)`), want: "12-34"},
+		{name: "pattern without group", raw: mail("", "PIN 12-34"), re: regexp.MustCompile(`\d+-\d+`), want: "12-34"},
+		{name: "not a mail", raw: []byte("garbage"), want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &IMAP{From: tt.from, Pattern: tt.re}
+			if got := m.codeOf(tt.raw, req); got != tt.want {
+				t.Errorf("code = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeServer answers each command read from nc with the next response;
+// "%s" in a response is replaced by the command's tag.
+func fakeServer(t *testing.T, nc net.Conn, responses ...string) {
+	t.Helper()
+	go func() {
+		defer nc.Close()
+		r := bufio.NewReader(nc)
+		for _, res := range responses {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			tag, _, _ := strings.Cut(line, " ")
+			if _, err := nc.Write([]byte(strings.ReplaceAll(res, "%s", tag))); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+func TestCmd(t *testing.T) {
+	big := bytes.Repeat([]byte("x"), maxLiteral+100)
+
+	tests := []struct {
+		name     string
+		response string
+		lines    []string
+		literals []string
+		err      string
+	}{
+		{
+			name:     "ok",
+			response: "* SEARCH 3 5 8\r\n%s OK SEARCH completed\r\n",
+			lines:    []string{"* SEARCH 3 5 8"},
+		},
+		{
+			name:     "no",
+			response: "%s NO [AUTHENTICATIONFAILED] Invalid credentials\r\n",
+			err:      "NO [AUTHENTICATIONFAILED] Invalid credentials",
+		},
+		{
+			name:     "literals",
+			response: "* 1 FETCH (UID 5 BODY[] {12}\r\nCode:\r\n 1234)\r\n* 2 FETCH (UID 6 BODY[] {0}\r\n)\r\n%s OK done\r\n",
+			lines:    []string{"* 1 FETCH (UID 5 BODY[] {12}", ")", "* 2 FETCH (UID 6 BODY[] {0}", ")"},
+			literals: []string{"Code:\r\n 1234", ""},
+		},
+		{
+			name:     "literal capped",
+			response: fmt.Sprintf("* 1 FETCH (BODY[] {%d}\r\n%s)\r\n%%s OK done\r\n", len(big), big),
+			lines:    []string{fmt.Sprintf("* 1 FETCH (BODY[] {%d}", len(big)), ")"},
+			literals: []string{string(big[:maxLiteral])},
+		},
+		{
+			name:     "negative literal",
+			response: "* 1 FETCH (BODY[] {-5}\r\n%s OK done\r\n",
+			lines:    []string{"* 1 FETCH (BODY[] {-5}"},
+		},
+		{
+			name:     "connection closed",
+			response: "* 1 FETCH (BODY[] {100}\r\nshort",
+			err:      "EOF",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			fakeServer(t, server, tt.response)
+
+			c := &conn{nc: client, r: bufio.NewReader(client)}
+			res, err := c.cmd("UID FETCH 5 BODY.PEEK[]")
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(res.lines, "\n") != strings.Join(tt.lines, "\n") {
+				t.Errorf("lines = %q, want %q", res.lines, tt.lines)
+			}
+			if len(res.literals) != len(tt.literals) {
+				t.Fatalf("%d literals, want %d", len(res.literals), len(tt.literals))
+			}
+			for i, lit := range res.literals {
+				if string(lit) != tt.literals[i] {
+					t.Errorf("literal %d = %.40q (%d bytes), want %.40q", i, lit, len(lit), tt.literals[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCmdTags(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	fakeServer(t, server,
+		"%s OK LOGIN completed\r\n",
+		// A tagged line of another command is not the completion.
+		"z9 OK stray\r\n%s OK EXAMINE completed\r\n",
+	)
+	c := &conn{nc: client, r: bufio.NewReader(client)}
+	if _, err := c.cmd(`LOGIN "u" "p"`); err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.cmd(`EXAMINE "INBOX"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.tag != 2 || len(res.lines) != 1 || res.lines[0] != "z9 OK stray" {
+		t.Fatalf("tag %d, lines %q", c.tag, res.lines)
+	}
+}
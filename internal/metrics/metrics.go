@@ -0,0 +1,214 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/browser"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/watcher"
+)
+
+var durationBuckets = []float64{1, 2, 5, 10, 20, 30, 60, 120}
+
+// Watcher collects the watcher metrics from its events and exposes them in
+// the Prometheus text format.
+type Watcher struct {
+	mu sync.Mutex
+
+	polls          uint64
+	pollFailures   uint64
+	durationCounts []uint64
+	durationSum    float64
+	durationCount  uint64
+
+	startFlowFailures map[string]uint64
+	slotsSeen         uint64
+	lastSlots         int
+	matchingSlots     uint64
+	bookingAttempts   uint64
+	bookingResults    map[string]uint64
+
+	lastSuccess time.Time
+	now         func() time.Time
+}
+
+func NewWatcher() *Watcher {
+	return &Watcher{
+		durationCounts:    make([]uint64, len(durationBuckets)),
+		startFlowFailures: map[string]uint64{},
+		bookingResults:    map[string]uint64{},
+		now:               time.Now,
+	}
+}
+
+// OnEvent implements watcher.Observer.
+func (m *Watcher) OnEvent(e watcher.Event) {
+	switch e.Kind {
+	case watcher.EventPollStarted:
+		m.mu.Lock()
+		m.polls++
+		m.mu.Unlock()
+	case watcher.EventSlotsListed:
+		matching := 0
+		for _, s := range e.Slots {
+			if s.Match {
+				matching++
+			}
+		}
+		m.slotsListed(len(e.Slots), matching)
+		m.observePoll(e.Elapsed, true)
+	case watcher.EventSlotMatched:
+		m.bookingAttempt()
+	case watcher.EventBooked:
+		m.bookingResult("booked")
+	case watcher.EventError:
+		switch e.Step {
+		case watcher.StepStartFlow:
+			step := "unknown"
+			var fe *browser.FlowError
+			if errors.As(e.Err, &fe) {
+				step = fe.Step
+			}
+			m.startFlowFailed(step)
+			m.observePoll(e.Elapsed, false)
+		case watcher.StepListSlots:
+			m.observePoll(e.Elapsed, false)
+		case watcher.StepBookSlot:
+			m.bookingResult("book_slot_failed")
+		case watcher.StepFillAndContinue:
+			m.bookingResult("fill_failed")
+		case watcher.StepConfirmBooking:
+			m.bookingResult("confirm_failed")
+		case watcher.StepVerify:
+			m.bookingResult("verify_failed")
+		}
+	}
+}
+
+// observePoll records a finished poll. ok is false if no slots could be listed.
+func (m *Watcher) observePoll(d time.Duration, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !ok {
+		m.pollFailures++
+	} else {
+		m.lastSuccess = m.now()
+	}
+	sec := d.Seconds()
+	for i, b := range durationBuckets {
+		if sec <= b {
+			m.durationCounts[i]++
+		}
+	}
+	m.durationSum += sec
+	m.durationCount++
+}
+
+// startFlowFailed counts a failed menu navigation at the given step.
+func (m *Watcher) startFlowFailed(step string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.startFlowFailures[step]++
+}
+
+func (m *Watcher) slotsListed(total, matching int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.slotsSeen += uint64(total)
+	m.lastSlots = total
+	m.matchingSlots += uint64(matching)
+}
+
+func (m *Watcher) bookingAttempt() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bookingAttempts++
+}
+
+// bookingResult counts the outcome of a booking attempt, e.g. "booked" or
+// the name of the step that failed.
+func (m *Watcher) bookingResult(result string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bookingResults[result]++
+}
+
+func (m *Watcher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format.
+func (m *Watcher) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cw := &countingWriter{w: w}
+	counter(cw, "zsb_polls_total", "Polls started by the watcher.", m.polls)
+	counter(cw, "zsb_poll_failures_total", "Polls that did not list any slots due to an error.", m.pollFailures)
+
+	fmt.Fprintln(cw, "# HELP zsb_poll_duration_seconds Duration of a poll from navigation to slot matching.")
+	fmt.Fprintln(cw, "# TYPE zsb_poll_duration_seconds histogram")
+	for i, b := range durationBuckets {
+		fmt.Fprintf(cw, "zsb_poll_duration_seconds_bucket{le=\"%g\"} %d\n", b, m.durationCounts[i])
+	}
+	fmt.Fprintf(cw, "zsb_poll_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.durationCount)
+	fmt.Fprintf(cw, "zsb_poll_duration_seconds_sum %g\n", m.durationSum)
+	fmt.Fprintf(cw, "zsb_poll_duration_seconds_count %d\n", m.durationCount)
+
+	labeled(cw, "zsb_startflow_failures_total", "Failed menu navigations by step.", "step", m.startFlowFailures)
+	counter(cw, "zsb_slots_seen_total", "Slots listed over all polls.", m.slotsSeen)
+	gauge(cw, "zsb_slots_listed", "Slots listed by the last successful poll.", float64(m.lastSlots))
+	counter(cw, "zsb_matching_slots_total", "Listed slots matching the requested availability.", m.matchingSlots)
+	counter(cw, "zsb_booking_attempts_total", "Attempts to book a matching slot.", m.bookingAttempts)
+	labeled(cw, "zsb_booking_results_total", "Outcomes of booking attempts.", "result", m.bookingResults)
+
+	since := -1.0
+	if !m.lastSuccess.IsZero() {
+		since = m.now().Sub(m.lastSuccess).Seconds()
+	}
+	gauge(cw, "zsb_seconds_since_last_successful_poll", "Seconds since slots were last listed, -1 before the first success.", since)
+	return cw.n, cw.err
+}
+
+func counter(w io.Writer, name, help string, v uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, v)
+}
+
+func gauge(w io.Writer, name, help string, v float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %g\n", name, help, name, name, v)
+}
+
+func labeled(w io.Writer, name, help, label string, vals map[string]uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	keys := make([]string, 0, len(vals))
+	for k := range vals {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s{%s=%q} %d\n", name, label, k, vals[k])
+	}
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
@@ -0,0 +1,166 @@
+package picker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/browser"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/watcher"
+)
+
+// Web offers the matching slots on a local web page and logs a link to it.
+// It implements watcher.Picker.
+type Web struct {
+	// URL is the address of the page as reachable by the user.
+	URL    string
+	Loc    *time.Location
+	Logger *slog.Logger
+
+	mu  sync.Mutex
+	cur *offer
+}
+
+type offer struct {
+	id    string
+	slots []browser.Slot
+	until time.Time
+	reply chan int
+}
+
+func (w *Web) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /pick", w.page)
+	mux.HandleFunc("POST /pick", w.choose)
+}
+
+func (w *Web) Pick(ctx context.Context, slots []browser.Slot) (int, error) {
+	var id [8]byte
+	_, _ = rand.Read(id[:])
+	o := &offer{id: hex.EncodeToString(id[:]), slots: slots, reply: make(chan int, 1)}
+	if dl, ok := ctx.Deadline(); ok {
+		o.until = dl
+	}
+
+	w.mu.Lock()
+	w.cur = o
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		if w.cur == o {
+			w.cur = nil
+		}
+		w.mu.Unlock()
+	}()
+
+	log := w.Logger
+	if log == nil {
+		log = slog.Default()
+	}
+	log.Warn("slot choice required", "url", w.URL, "count", len(slots))
+
+	select {
+	case i := <-o.reply:
+		if i < 0 {
+			return 0, watcher.ErrSkipped
+		}
+		return i, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+var pageTmpl = template.Must(template.New("pick").Parse(`<!doctype html>
+<html lang="de">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+{{if not .ID}}<meta http-equiv="refresh" content="10">{{end}}
+<title>Termin auswählen</title>
+<style>
+body { font-family: sans-serif; max-width: 32rem; margin: 2rem auto; padding: 0 1rem; }
+button { display: block; width: 100%; margin: .5rem 0; padding: .75rem; font-size: 1rem; }
+.skip { background: none; border: 1px solid #999; }
+</style>
+</head>
+<body>
+{{if .Message}}<p>{{.Message}}</p>{{end}}
+{{if .ID}}
+<h1>Termin auswählen</h1>
+{{if .Until}}<p>Auswahl möglich bis {{.Until}} Uhr.</p>{{end}}
+<form method="post" action="pick">
+<input type="hidden" name="id" value="{{.ID}}">
+{{range $i, $s := .Slots}}<button name="slot" value="{{$i}}">{{$s}}</button>
+{{end}}<button class="skip" name="slot" value="-1">Keinen davon buchen</button>
+</form>
+{{else if not .Message}}
+<p>Zurzeit steht keine Auswahl an. Die Seite aktualisiert sich automatisch.</p>
+{{end}}
+</body>
+</html>
+`))
+
+type pageData struct {
+	ID      string
+	Slots   []string
+	Until   string
+	Message string
+}
+
+func (w *Web) page(rw http.ResponseWriter, r *http.Request) {
+	w.mu.Lock()
+	o := w.cur
+	w.mu.Unlock()
+
+	var data pageData
+	if o != nil {
+		data.ID = o.id
+		for _, s := range o.slots {
+			label := s.Start.In(w.Loc).Format("Mon 02.01.2006 15:04")
+			if !s.End.IsZero() {
+				label += "–" + s.End.In(w.Loc).Format("15:04")
+			}
+			data.Slots = append(data.Slots, label)
+		}
+		if !o.until.IsZero() {
+			data.Until = o.until.In(w.Loc).Format("15:04:05")
+		}
+	}
+	render(rw, data)
+}
+
+func (w *Web) choose(rw http.ResponseWriter, r *http.Request) {
+	i, err := strconv.Atoi(r.FormValue("slot"))
+	if err != nil {
+		http.Error(rw, "invalid slot", http.StatusBadRequest)
+		return
+	}
+
+	w.mu.Lock()
+	o := w.cur
+	w.mu.Unlock()
+
+	if o == nil || o.id != r.FormValue("id") || i >= len(o.slots) {
+		render(rw, pageData{Message: "Diese Auswahl ist nicht mehr gültig."})
+		return
+	}
+	select {
+	case o.reply <- i:
+	default:
+	}
+	msg := "Kein Termin wird gebucht, die Suche läuft weiter."
+	if i >= 0 {
+		msg = "Danke! Der Termin am " + o.slots[i].Start.In(w.Loc).Format("02.01.2006 um 15:04") + " Uhr wird gebucht."
+	}
+	render(rw, pageData{Message: msg})
+}
+
+func render(rw http.ResponseWriter, data pageData) {
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = pageTmpl.Execute(rw, data)
+}
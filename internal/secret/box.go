@@ -0,0 +1,134 @@
+// Package secret encrypts personal data before it is written to disk.
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	prefix       = "v1:"
+	saltSize     = 16
+	pbkdf2Rounds = 600_000
+	minKeyFile   = 32
+)
+
+var ErrDecrypt = errors.New("secret: cannot decrypt, wrong passphrase or key file?")
+
+// Box seals values with AES-256-GCM. The key is derived from a passphrase
+// (PBKDF2-SHA256) or from the contents of a key file (HKDF-SHA256), using a
+// random salt that is stored with every sealed value.
+type Box struct {
+	derive func(salt []byte) ([]byte, error)
+
+	mu   sync.Mutex
+	salt []byte
+	keys map[string][]byte
+}
+
+func FromPassphrase(pass string) (*Box, error) {
+	if pass == "" {
+		return nil, errors.New("secret: empty passphrase")
+	}
+	return newBox(func(salt []byte) ([]byte, error) {
+		return pbkdf2.Key(sha256.New, pass, salt, pbkdf2Rounds, 32)
+	}), nil
+}
+
+// FromKeyFile uses the contents of path as key material. The file should
+// hold at least 32 random bytes, e.g. from `head -c 32 /dev/urandom`.
+func FromKeyFile(path string) (*Box, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("secret: key file: %w", err)
+	}
+	if len(b) < minKeyFile {
+		return nil, fmt.Errorf("secret: key file %s is shorter than %d bytes", path, minKeyFile)
+	}
+	return newBox(func(salt []byte) ([]byte, error) {
+		return hkdf.Key(sha256.New, b, salt, "zulassungsstellebot state", 32)
+	}), nil
+}
+
+func newBox(derive func([]byte) ([]byte, error)) *Box {
+	return &Box{derive: derive, keys: map[string][]byte{}}
+}
+
+// IsSealed reports whether s was produced by Seal.
+func IsSealed(s string) bool { return strings.HasPrefix(s, prefix) }
+
+func (b *Box) Seal(plain []byte) (string, error) {
+	b.mu.Lock()
+	if b.salt == nil {
+		b.salt = make([]byte, saltSize)
+		_, _ = rand.Read(b.salt)
+	}
+	salt := b.salt
+	b.mu.Unlock()
+
+	aead, err := b.aead(salt)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, _ = rand.Read(nonce)
+
+	out := append(append([]byte{}, salt...), nonce...)
+	out = aead.Seal(out, nonce, plain, nil)
+	return prefix + base64.RawStdEncoding.EncodeToString(out), nil
+}
+
+func (b *Box) Open(sealed string) ([]byte, error) {
+	if !IsSealed(sealed) {
+		return nil, errors.New("secret: not a sealed value")
+	}
+	raw, err := base64.RawStdEncoding.DecodeString(sealed[len(prefix):])
+	if err != nil || len(raw) < saltSize {
+		return nil, errors.New("secret: malformed sealed value")
+	}
+	aead, err := b.aead(raw[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	raw = raw[saltSize:]
+	if len(raw) < aead.NonceSize() {
+		return nil, errors.New("secret: malformed sealed value")
+	}
+	plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plain, nil
+}
+
+// aead derives the key for salt once and caches it, PBKDF2 is slow on
+// purpose.
+func (b *Box) aead(salt []byte) (cipher.AEAD, error) {
+	b.mu.Lock()
+	key, ok := b.keys[string(salt)]
+	b.mu.Unlock()
+	if !ok {
+		var err error
+		if key, err = b.derive(salt); err != nil {
+			return nil, err
+		}
+		b.mu.Lock()
+		b.keys[string(salt)] = key
+		b.mu.Unlock()
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,59 @@
+package tui
+
+import (
+	"context"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/watcher"
+)
+
+// CodePrompt asks for the emailed verification code in the live dashboard.
+// It implements watcher.CodeSource.
+type CodePrompt struct {
+	reqs chan codeRequest
+}
+
+type codeRequest struct {
+	watcher.CodeRequest
+	reply chan string
+	done  <-chan struct{}
+}
+
+type codeMsg codeRequest
+
+func NewCodePrompt() *CodePrompt { return &CodePrompt{reqs: make(chan codeRequest)} }
+
+func (p *CodePrompt) Code(ctx context.Context, r watcher.CodeRequest) (string, error) {
+	req := codeRequest{CodeRequest: r, reply: make(chan string, 1), done: ctx.Done()}
+	select {
+	case p.reqs <- req:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	select {
+	case code := <-req.reply:
+		if code == "" {
+			return "", watcher.ErrCodeDeclined
+		}
+		return code, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func (p *CodePrompt) next() tea.Cmd {
+	if p == nil {
+		return nil
+	}
+	return func() tea.Msg { return codeMsg(<-p.reqs) }
+}
+
+func (r *codeRequest) expired() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
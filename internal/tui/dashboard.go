@@ -0,0 +1,304 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/watcher"
+)
+
+const (
+	dashMaxSlots  = 12
+	dashMaxErrors = 5
+)
+
+type dashTickMsg time.Time
+
+type dashDoneMsg struct{}
+
+type DashboardOptions struct {
+	Status  *watcher.Status
+	Control *watcher.Control
+	// Picker is optional, see watcher.Config.Picker.
+	Picker *Picker
+	// Code is optional, see watcher.Config.Code.
+	Code *CodePrompt
+	// Done is closed when the watcher has returned.
+	Done <-chan struct{}
+	Loc  *time.Location
+}
+
+type dashboard struct {
+	st     *watcher.Status
+	ctl    *watcher.Control
+	picker *Picker
+	codes  *CodePrompt
+	done   <-chan struct{}
+	loc    *time.Location
+
+	snap     watcher.StatusSnapshot
+	finished bool
+
+	pick       *pickRequest
+	pickCursor int
+
+	code      *codeRequest
+	codeInput textinput.Model
+}
+
+// RunDashboard shows the live state of a running watcher until Done is
+// closed or the user quits. In the latter case context.Canceled is returned
+// and the caller should stop the watcher.
+func RunDashboard(ctx context.Context, opts DashboardOptions) error {
+	fmt.Print("\033[H\033[2J")
+
+	d := dashboard{
+		st:     opts.Status,
+		ctl:    opts.Control,
+		picker: opts.Picker,
+		codes:  opts.Code,
+		done:   opts.Done,
+		loc:    opts.Loc,
+		snap:   opts.Status.Snapshot(),
+	}
+	p := tea.NewProgram(d)
+
+	go func() {
+		<-ctx.Done()
+		p.Quit()
+	}()
+
+	res, err := p.Run()
+	if err != nil {
+		return err
+	}
+	final, ok := res.(dashboard)
+	if !ok {
+		return fmt.Errorf("unexpected model type")
+	}
+	if !final.finished {
+		return context.Canceled
+	}
+	return nil
+}
+
+func dashTick() tea.Cmd {
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg { return dashTickMsg(t) })
+}
+
+func (d dashboard) Init() tea.Cmd {
+	done := d.done
+	return tea.Batch(dashTick(), d.picker.next(), d.codes.next(), func() tea.Msg {
+		<-done
+		return dashDoneMsg{}
+	})
+}
+
+func (d dashboard) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch k := msg.(type) {
+	case dashTickMsg:
+		d.snap = d.st.Snapshot()
+		if d.pick != nil && d.pick.expired() {
+			d.pick = nil
+			return d, tea.Batch(dashTick(), d.picker.next())
+		}
+		if d.code != nil && d.code.expired() {
+			d.code = nil
+			return d, tea.Batch(dashTick(), d.codes.next())
+		}
+		return d, dashTick()
+	case pickMsg:
+		r := pickRequest(k)
+		d.pick = &r
+		d.pickCursor = 0
+		return d, nil
+	case codeMsg:
+		r := codeRequest(k)
+		d.code = &r
+		d.codeInput = textinput.New()
+		d.codeInput.Placeholder = "Code"
+		d.codeInput.CharLimit = 20
+		d.codeInput.Width = 20
+		return d, d.codeInput.Focus()
+	case dashDoneMsg:
+		d.snap = d.st.Snapshot()
+		d.finished = true
+		return d, tea.Quit
+	case tea.KeyMsg:
+		if d.code != nil {
+			switch k.String() {
+			case "enter":
+				if strings.TrimSpace(d.codeInput.Value()) == "" {
+					return d, nil
+				}
+				d.code.reply <- d.codeInput.Value()
+				d.code = nil
+				return d, d.codes.next()
+			case "esc":
+				d.code.reply <- ""
+				d.code = nil
+				return d, d.codes.next()
+			case "ctrl+c":
+				return d, tea.Quit
+			}
+			var cmd tea.Cmd
+			d.codeInput, cmd = d.codeInput.Update(k)
+			return d, cmd
+		}
+		if d.pick != nil {
+			switch k.String() {
+			case "up", "k":
+				if d.pickCursor > 0 {
+					d.pickCursor--
+				}
+				return d, nil
+			case "down", "j":
+				if d.pickCursor < len(d.pick.slots)-1 {
+					d.pickCursor++
+				}
+				return d, nil
+			case "enter":
+				d.pick.reply <- d.pickCursor
+				d.pick = nil
+				return d, d.picker.next()
+			case "s", "esc":
+				d.pick.reply <- -1
+				d.pick = nil
+				return d, d.picker.next()
+			}
+		}
+		switch k.String() {
+		case "p":
+			if d.ctl.Paused() {
+				d.ctl.Resume()
+			} else {
+				d.ctl.Pause()
+			}
+			return d, nil
+		case "n", "enter":
+			d.ctl.PollNow()
+			return d, nil
+		case "q", "esc", "ctrl+c":
+			return d, tea.Quit
+		}
+	}
+	return d, nil
+}
+
+func (d dashboard) View() string {
+	var b strings.Builder
+	b.WriteString(carArt)
+	b.WriteString("\n\n👀 Terminsuche läuft\n\n")
+
+	s := d.snap
+	fmt.Fprintf(&b, "Status:  %s\n", d.stateLine(s))
+	fmt.Fprintf(&b, "Abfrage: #%d", s.Poll)
+	if !s.LastPoll.IsZero() {
+		fmt.Fprintf(&b, " · zuletzt erfolgreich %s", s.LastPoll.In(d.loc).Format("15:04:05"))
+	}
+	b.WriteString("\n\n")
+
+	if d.code != nil {
+		fmt.Fprintf(&b, "📧 Bitte den Bestätigungscode aus der E-Mail an %s eingeben (bis %s):\n\n",
+			d.code.Email, d.code.Deadline.In(d.loc).Format("15:04"))
+		b.WriteString("  " + d.codeInput.View() + "\n\n")
+		b.WriteString("Enter: bestätigen · Esc: abbrechen\n")
+		return b.String()
+	}
+
+	if d.pick != nil {
+		b.WriteString("🙋 Bitte Termin auswählen:\n")
+		for i, sl := range d.pick.slots {
+			cursor := "  "
+			if i == d.pickCursor {
+				cursor = "➤ "
+			}
+			fmt.Fprintf(&b, "%s%s\n", cursor, slotLabel(sl.Start, sl.End, sl.Capacity, sl.Resource, d.loc))
+		}
+		b.WriteString("\n↑/↓: bewegen · Enter: buchen · s/Esc: keinen buchen\n")
+		return b.String()
+	}
+
+	if len(s.Slots) == 0 {
+		b.WriteString("Freie Termine: (keine)\n\n")
+	} else {
+		fmt.Fprintf(&b, "Freie Termine (%d):\n", len(s.Slots))
+		for i, sl := range s.Slots {
+			if i == dashMaxSlots {
+				fmt.Fprintf(&b, "  … und %d weitere\n", len(s.Slots)-dashMaxSlots)
+				break
+			}
+			mark := "✘"
+			if sl.Match {
+				mark = "✔"
+			}
+			fmt.Fprintf(&b, "  %s %s\n", mark, slotLabel(sl.Start, sl.End, sl.Capacity, sl.Resource, d.loc))
+		}
+		b.WriteString("\n")
+	}
+
+	if len(s.Errors) > 0 {
+		b.WriteString("Fehler:\n")
+		from := max(0, len(s.Errors)-dashMaxErrors)
+		for _, e := range s.Errors[from:] {
+			fmt.Fprintf(&b, "  ⚠️  %s  %s\n", e.At.In(d.loc).Format("15:04:05"), e.Err)
+		}
+		b.WriteString("\n")
+	}
+
+	pause := "p: pausieren"
+	if d.ctl.Paused() {
+		pause = "p: fortsetzen"
+	}
+	b.WriteString(pause + " · n/Enter: jetzt abfragen · q: beenden\n")
+	return b.String()
+}
+
+func (d dashboard) stateLine(s watcher.StatusSnapshot) string {
+	switch s.State {
+	case watcher.StateStarting:
+		return "startet…"
+	case watcher.StateNavigating:
+		return "navigiert durchs Menü…"
+	case watcher.StateListing:
+		return "liest freie Termine…"
+	case watcher.StatePicking:
+		return "wartet auf Auswahl…"
+	case watcher.StateBooking:
+		return "bucht Termin…"
+	case watcher.StateAwaitingCode:
+		return "wartet auf Bestätigungscode bis " + s.CodeDeadline.In(d.loc).Format("15:04:05")
+	case watcher.StateSleeping:
+		return "wartet bis " + s.NextPoll.In(d.loc).Format("15:04:05")
+	case watcher.StatePaused:
+		if d.ctl.Paused() {
+			return "pausiert"
+		}
+		return "pausiert bis " + s.NextPoll.In(d.loc).Format("15:04")
+	case watcher.StateBooked:
+		return "✅ Termin gebucht"
+	case watcher.StateStopped:
+		return "beendet"
+	}
+	return string(s.State)
+}
+
+// slotLabel formats a slot like "Di 21.10.2025 09:30–09:45 · 2 frei · Schalter 3".
+func slotLabel(start, end time.Time, capacity int, resource string, loc *time.Location) string {
+	l := start.In(loc).Format("Mon 02.01.2006 15:04")
+	if !end.IsZero() {
+		l += "–" + end.In(loc).Format("15:04")
+	}
+	if capacity > 0 {
+		l += fmt.Sprintf(" · %d frei", capacity)
+	}
+	if resource != "" {
+		l += " · " + resource
+	}
+	return l
+}
@@ -0,0 +1,126 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/domain"
+)
+
+// initFields prepares one input per extra field of the chosen service.
+func initFields(m *Model, fields []domain.FormField) {
+	m.fields = fields
+	m.fieldInputs = make([]textinput.Model, len(fields))
+	m.fieldFocus = 0
+	for i, f := range fields {
+		in := textinput.New()
+		in.Width = 40
+		in.CharLimit = 200
+		switch {
+		case f.Hint != "":
+			in.Placeholder = f.Hint
+		case f.Kind() == domain.FieldSelect:
+			in.Placeholder = strings.Join(f.Options, " / ")
+		case f.Kind() == domain.FieldCheckbox:
+			in.Placeholder = "ja / nein"
+		}
+		m.fieldInputs[i] = in
+	}
+	if len(m.fieldInputs) > 0 {
+		m.fieldInputs[0].Focus()
+	}
+}
+
+func focusField(m *Model, i int) {
+	if i < 0 || i >= len(m.fieldInputs) {
+		return
+	}
+	m.fieldInputs[m.fieldFocus].Blur()
+	m.fieldFocus = i
+	m.fieldInputs[i].Focus()
+}
+
+func updateFields(m Model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch k := msg.(type) {
+	case tea.KeyMsg:
+		switch k.String() {
+		case "enter":
+			f := m.fields[m.fieldFocus]
+			if err := f.Validate(m.fieldInputs[m.fieldFocus].Value()); err != nil {
+				m.errMsg = err.Error()
+				return m, nil
+			}
+			m.errMsg = ""
+			if m.fieldFocus < len(m.fields)-1 {
+				focusField(&m, m.fieldFocus+1)
+				return m, nil
+			}
+			for i, f := range m.fields {
+				if err := f.Validate(m.fieldInputs[i].Value()); err != nil {
+					focusField(&m, i)
+					m.errMsg = err.Error()
+					return m, nil
+				}
+			}
+			m.step = stepAvailabilityMode
+			return m, nil
+		case "tab", "down":
+			focusField(&m, (m.fieldFocus+1)%len(m.fieldInputs))
+			return m, nil
+		case "shift+tab", "up":
+			focusField(&m, (m.fieldFocus+len(m.fieldInputs)-1)%len(m.fieldInputs))
+			return m, nil
+		case "esc":
+			m.errMsg = ""
+			m.step = stepMenu
+			return m, nil
+		}
+	}
+	var cmd tea.Cmd
+	m.fieldInputs[m.fieldFocus], cmd = m.fieldInputs[m.fieldFocus].Update(msg)
+	return m, cmd
+}
+
+func viewFields(m Model) string {
+	var b strings.Builder
+	b.WriteString("🗂️  Weitere Angaben für " + leafTitle(&m) + "\n\n")
+	for i, f := range m.fields {
+		cursor := "  "
+		if i == m.fieldFocus {
+			cursor = "➤ "
+		}
+		label := f.Label
+		if f.Optional {
+			label += " (optional)"
+		}
+		b.WriteString(cursor + label + ":\n  " + m.fieldInputs[i].View() + "\n\n")
+	}
+	if m.errMsg != "" {
+		b.WriteString("⚠️  " + m.errMsg + "\n\n")
+	}
+	b.WriteString("Enter: weiter · Tab/↑/↓: Feld wechseln · Esc: zurück\n")
+	return b.String()
+}
+
+// fieldValues returns the entered values by label, nil without fields.
+func fieldValues(m Model) map[string]string {
+	if len(m.fields) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(m.fields))
+	for i, f := range m.fields {
+		if v := strings.TrimSpace(m.fieldInputs[i].Value()); v != "" {
+			out[f.Label] = v
+		}
+	}
+	return out
+}
+
+func leafTitle(m *Model) string {
+	if len(m.path) == 0 {
+		return ""
+	}
+	return m.path[len(m.path)-1]
+}
@@ -17,6 +17,7 @@ type step int
 const (
 	stepPerson step = iota
 	stepMenu
+	stepFields
 	stepAvailabilityMode
 	stepAvailabilityDetail
 	stepReview
@@ -49,6 +50,11 @@ type Model struct {
 	path          []string
 	menuSelectors []string
 
+	// ---- Extra fields of the chosen service ----
+	fields      []domain.FormField
+	fieldInputs []textinput.Model
+	fieldFocus  int
+
 	mode        domain.AvailabilityKind
 	availCursor int
 
@@ -102,6 +108,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return updatePerson(m, msg)
 	case stepMenu:
 		return updateMenu(m, msg)
+	case stepFields:
+		return updateFields(m, msg)
 	case stepAvailabilityMode:
 		return updateAvailMode(m, msg)
 	case stepAvailabilityDetail:
@@ -125,6 +133,8 @@ func (m Model) View() string {
 		s.WriteString(viewPerson(m))
 	case stepMenu:
 		s.WriteString(viewMenu(m))
+	case stepFields:
+		s.WriteString(viewFields(m))
 	case stepAvailabilityMode:
 		s.WriteString(viewAvailMode(m))
 	case stepAvailabilityDetail:
@@ -256,6 +266,12 @@ func updateMenu(m Model, msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.path = append(currentPathTitles(&m), n.Title)
 			selectors := currentPathSelectors(&m, m.menuCursor)
 			m.menuSelectors = selectors
+			if len(n.Fields) > 0 {
+				initFields(&m, n.Fields)
+				m.step = stepFields
+				return m, nil
+			}
+			m.fields, m.fieldInputs = nil, nil
 			m.step = stepAvailabilityMode
 			return m, nil
 		case "left", "h":
@@ -312,6 +328,9 @@ func updateAvailMode(m Model, msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		case "left", "h":
 			m.step = stepMenu
+			if len(m.fields) > 0 {
+				m.step = stepFields
+			}
 			return m, nil
 		case "esc":
 			return m, tea.Quit
@@ -549,12 +568,16 @@ func updateReview(m Model, msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 			br := domain.BookingRequest{
-				Name:  m.nameInput.Value(),
-				Email: m.emailInput.Value(),
-				Phone: m.phoneInput.Value(),
+				PersonalData: domain.PersonalData{
+					Name:  m.nameInput.Value(),
+					Email: m.emailInput.Value(),
+					Phone: m.phoneInput.Value(),
+					Extra: fieldValues(m),
+				},
 				Menu: domain.MenuChoice{
 					Path:      append([]string{}, m.path...),
 					Selectors: append([]string{}, m.menuSelectors...),
+					Fields:    m.fields,
 				},
 				TZ: m.cfg.TZ,
 			}
@@ -0,0 +1,61 @@
+package tui
+
+import (
+	"context"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/browser"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/watcher"
+)
+
+// Picker offers the matching slots in the live dashboard and lets the user
+// choose one. It implements watcher.Picker.
+type Picker struct {
+	reqs chan pickRequest
+}
+
+type pickRequest struct {
+	slots []browser.Slot
+	reply chan int
+	done  <-chan struct{}
+}
+
+type pickMsg pickRequest
+
+func NewPicker() *Picker { return &Picker{reqs: make(chan pickRequest)} }
+
+func (p *Picker) Pick(ctx context.Context, slots []browser.Slot) (int, error) {
+	r := pickRequest{slots: slots, reply: make(chan int, 1), done: ctx.Done()}
+	select {
+	case p.reqs <- r:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+	select {
+	case i := <-r.reply:
+		if i < 0 {
+			return 0, watcher.ErrSkipped
+		}
+		return i, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func (p *Picker) next() tea.Cmd {
+	if p == nil {
+		return nil
+	}
+	return func() tea.Msg { return pickMsg(<-p.reqs) }
+}
+
+// expired reports whether the watcher stopped waiting for this choice.
+func (r *pickRequest) expired() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
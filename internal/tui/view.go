@@ -135,6 +135,15 @@ func viewReview(m Model) string {
 
 	b.WriteString("Menü:   " + breadcrumb(&m) + "\n\n")
 
+	if vals := fieldValues(m); len(vals) > 0 {
+		for _, f := range m.fields {
+			if v, ok := vals[f.Label]; ok {
+				b.WriteString(f.Label + ": " + v + "\n")
+			}
+		}
+		b.WriteString("\n")
+	}
+
 	if m.mode == domain.AvailOneOff {
 		b.WriteString(fmt.Sprintf("Verfügbarkeit: Einmalig — %s (%02d–%02d)\n\n",
 			m.dateISO, m.fromHour, m.toHour))
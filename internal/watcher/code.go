@@ -0,0 +1,127 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/browser"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/domain"
+)
+
+// ErrNoCodeSource is returned when the site asks for a verification code
+// but Config.Code is not set.
+var ErrNoCodeSource = errors.New("verification code requested, but no code source configured")
+
+// ErrCodeDeclined is returned by a CodeSource when the user declined to
+// enter the code.
+var ErrCodeDeclined = errors.New("no verification code entered")
+
+// ErrUnverified is returned by Run when the booking was confirmed, but the
+// code asked for afterwards could not be entered. Polling on could book a
+// second appointment, so Run gives up instead.
+var ErrUnverified = errors.New("booking confirmed, but not verified")
+
+// CodeRequest describes the code the site has emailed.
+type CodeRequest struct {
+	// Email is the address the code was sent to.
+	Email string
+	// Since is when the booking form was submitted; older mails do not
+	// contain the code.
+	Since time.Time
+	// Deadline is when the watcher stops waiting.
+	Deadline time.Time
+}
+
+// CodeSource supplies the verification code of a booking, typed in by a
+// human or read from the mailbox. Code must give up when ctx is done.
+type CodeSource interface {
+	Code(ctx context.Context, r CodeRequest) (string, error)
+}
+
+// AnyCode asks all sources at once and returns the first code any of them
+// provides. Nil sources are ignored.
+func AnyCode(sources ...CodeSource) CodeSource {
+	var srcs anyCode
+	for _, s := range sources {
+		if s != nil {
+			srcs = append(srcs, s)
+		}
+	}
+	switch len(srcs) {
+	case 0:
+		return nil
+	case 1:
+		return srcs[0]
+	}
+	return srcs
+}
+
+type anyCode []CodeSource
+
+func (a anyCode) Code(ctx context.Context, r CodeRequest) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		code string
+		err  error
+	}
+	ch := make(chan result, len(a))
+	for _, s := range a {
+		go func() {
+			code, err := s.Code(ctx, r)
+			ch <- result{code, err}
+		}()
+	}
+	var errs []error
+	for range a {
+		res := <-ch
+		if res.err == nil {
+			return res.code, nil
+		}
+		errs = append(errs, res.err)
+	}
+	return "", errors.Join(errs...)
+}
+
+// verify enters the emailed code if the site asks for one. since is when
+// the booking form was submitted. After the confirmation, confirmed is set:
+// a failed check for the code input then counts as no code needed.
+func (c Config) verify(ctx context.Context, drv browser.Driver, req domain.BookingRequest, since time.Time, confirmed bool, log *slog.Logger, emit func(Event), poll int, slot time.Time) error {
+	need, err := drv.NeedsCode(ctx)
+	if err != nil && confirmed {
+		log.Warn("checking for a verification code failed, assuming none is needed", "err", err)
+		return nil
+	}
+	if err != nil || !need {
+		return err
+	}
+	if c.Code == nil {
+		return ErrNoCodeSource
+	}
+
+	timeout := c.CodeTimeout
+	if timeout <= 0 {
+		timeout = 10 * time.Minute
+	}
+	r := CodeRequest{Email: req.Email, Since: since, Deadline: time.Now().Add(timeout)}
+	log.Info("waiting for verification code", "deadline", r.Deadline.Format("15:04:05"))
+	emit(Event{Kind: EventCodeRequested, Poll: poll, Slot: slot, Until: r.Deadline})
+
+	cctx, cancel := context.WithDeadline(ctx, r.Deadline)
+	code, err := c.Code.Code(cctx, r)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("verification code: %w", err)
+	}
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return errors.New("verification code: empty")
+	}
+	emit(Event{Kind: EventBookingStep, Poll: poll, Slot: slot, Step: StepVerify})
+	return drv.EnterCode(ctx, code)
+}
@@ -0,0 +1,141 @@
+package watcher
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Control lets a user interface pause, resume or hurry a running watcher.
+// All methods are safe to call on a nil *Control.
+type Control struct {
+	mu     sync.Mutex
+	paused bool
+	resume chan struct{}
+	wake   chan struct{}
+	// waiting is set between arm and the end of the next sleep; PollNow
+	// only wakes the watcher then.
+	waiting bool
+}
+
+func NewControl() *Control {
+	return &Control{resume: make(chan struct{}), wake: make(chan struct{}, 1)}
+}
+
+// Pause stops polling after the current poll has finished.
+func (c *Control) Pause() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = true
+}
+
+func (c *Control) Resume() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused {
+		c.paused = false
+		close(c.resume)
+		c.resume = make(chan struct{})
+	}
+}
+
+func (c *Control) Paused() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+// PollNow cuts the current wait short. A paused watcher is resumed. While
+// the watcher is polling it has no effect.
+func (c *Control) PollNow() {
+	if c == nil {
+		return
+	}
+	c.Resume()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.waiting {
+		return
+	}
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// arm marks the start of a wait, before it is announced to the observers,
+// so that PollNow from an observer is not lost.
+func (c *Control) arm() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waiting = true
+}
+
+// waitResumed blocks while the watcher is paused. It returns false if ctx
+// was cancelled first.
+func (c *Control) waitResumed(ctx context.Context) bool {
+	if c == nil {
+		return ctx.Err() == nil
+	}
+	c.mu.Lock()
+	paused, resume := c.paused, c.resume
+	c.mu.Unlock()
+	if !paused {
+		return ctx.Err() == nil
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-resume:
+		return true
+	}
+}
+
+func (c *Control) wakeup() <-chan struct{} {
+	if c == nil {
+		return nil
+	}
+	return c.wake
+}
+
+// sleep waits for d, until ctx is done or until PollNow is called. It
+// reports whether the wait was cut short by PollNow.
+func (c *Control) sleep(ctx context.Context, d time.Duration) bool {
+	c.arm()
+	defer c.disarm()
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	case <-c.wakeup():
+		return true
+	}
+	return false
+}
+
+// disarm ends a wait and drops a wakeup that arrived too late.
+func (c *Control) disarm() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waiting = false
+	select {
+	case <-c.wake:
+	default:
+	}
+}
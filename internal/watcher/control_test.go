@@ -0,0 +1,26 @@
+package watcher
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestPollNowWhilePolling makes sure a PollNow during a poll does not cut
+// the following wait short.
+func TestPollNowWhilePolling(t *testing.T) {
+	ctl := NewControl()
+	ctl.PollNow()
+	if ctl.sleep(context.Background(), 50*time.Millisecond) {
+		t.Fatal("sleep woken by a PollNow before it started")
+	}
+
+	ctl.arm()
+	ctl.PollNow()
+	if !ctl.sleep(context.Background(), time.Minute) {
+		t.Fatal("sleep not woken by PollNow after arm")
+	}
+	if ctl.sleep(context.Background(), 50*time.Millisecond) {
+		t.Fatal("wakeup carried over to the next sleep")
+	}
+}
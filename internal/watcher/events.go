@@ -0,0 +1,115 @@
+package watcher
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type EventKind string
+
+const (
+	EventPollStarted EventKind = "poll_started"
+	// EventFlowReady follows a successful StartFlow, the calendar is shown.
+	EventFlowReady   EventKind = "flow_ready"
+	EventSlotsListed EventKind = "slots_listed"
+	// EventPickRequested is emitted when a Picker is asked to choose one of
+	// the matching Slots.
+	EventPickRequested EventKind = "pick_requested"
+	EventSlotMatched   EventKind = "slot_matched"
+	// EventBookingStep is emitted before each step of the booking sequence.
+	EventBookingStep EventKind = "booking_step"
+	// EventCodeRequested is emitted when the site asks for the code it
+	// emailed; Until is the deadline for entering it.
+	EventCodeRequested EventKind = "code_requested"
+	// EventConfirmed follows a successful ConfirmBooking. The site has
+	// taken the booking, Run will not poll again even if the code check
+	// that may follow fails.
+	EventConfirmed EventKind = "confirmed"
+	EventBooked    EventKind = "booked"
+	EventError     EventKind = "error"
+	// EventSleeping is emitted whenever Run waits: between polls, during a
+	// schedule pause (Paused, Until set) or while paused by the user (Paused,
+	// Until zero).
+	EventSleeping EventKind = "sleeping"
+	// EventStopped is the last event of every Run.
+	EventStopped EventKind = "stopped"
+)
+
+// Steps reported in EventBookingStep and EventError.
+const (
+	StepOpen            = "Open"
+	StepStartFlow       = "StartFlow"
+	StepListSlots       = "ListSlots"
+	StepPick            = "Pick"
+	StepBookSlot        = "BookSlot"
+	StepFillAndContinue = "FillAndContinue"
+	StepConfirmBooking  = "ConfirmBooking"
+	StepVerify          = "Verify"
+	StepStats           = "Stats"
+	StepHistory         = "History"
+)
+
+// Event describes something that happened inside Run. Only the fields
+// relevant for Kind are set.
+type Event struct {
+	Kind EventKind `json:"kind"`
+	At   time.Time `json:"at"`
+	Poll int       `json:"poll,omitempty"`
+	// Elapsed is the time since the poll started, set on EventSlotsListed and
+	// on errors of StartFlow and ListSlots.
+	Elapsed time.Duration `json:"elapsed,omitempty"`
+
+	Slots []SlotStatus `json:"slots,omitempty"`
+	// Slot is the start of the slot being booked.
+	Slot time.Time `json:"slot,omitzero"`
+	Step string    `json:"step,omitempty"`
+	Err  error     `json:"-"`
+
+	Until  time.Time `json:"until,omitzero"`
+	Paused bool      `json:"paused,omitempty"`
+}
+
+func (e Event) MarshalJSON() ([]byte, error) {
+	type plain Event
+	var msg string
+	if e.Err != nil {
+		msg = e.Err.Error()
+	}
+	return json.Marshal(struct {
+		plain
+		Error string `json:"error,omitempty"`
+	}{plain(e), msg})
+}
+
+// Observer receives the events of a running watcher. OnEvent is called
+// synchronously from Run and must not block.
+type Observer interface {
+	OnEvent(Event)
+}
+
+type ObserverFunc func(Event)
+
+func (f ObserverFunc) OnEvent(e Event) { f(e) }
+
+// Chan returns an Observer that forwards events to a channel with the given
+// buffer size. Events are dropped while the buffer is full.
+func Chan(buf int) (Observer, <-chan Event) {
+	ch := make(chan Event, buf)
+	return ObserverFunc(func(e Event) {
+		select {
+		case ch <- e:
+		default:
+		}
+	}), ch
+}
+
+type observers []Observer
+
+func (o observers) emit(e Event) {
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	for _, ob := range o {
+		ob.OnEvent(e)
+	}
+}
@@ -0,0 +1,37 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/browser"
+)
+
+// ErrSkipped is returned by a Picker when the user declined all slots.
+var ErrSkipped = errors.New("no slot chosen")
+
+// Picker lets a human choose which of the matching slots to book. Pick
+// returns the index of the chosen slot and must give up when ctx is done.
+type Picker interface {
+	Pick(ctx context.Context, slots []browser.Slot) (int, error)
+}
+
+func (c Config) pick(ctx context.Context, slots []browser.Slot) (int, error) {
+	timeout := c.PickTimeout
+	if timeout <= 0 {
+		timeout = 2 * time.Minute
+	}
+	pctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	i, err := c.Picker.Pick(pctx, slots)
+	if err != nil {
+		return 0, err
+	}
+	if i < 0 || i >= len(slots) {
+		return 0, fmt.Errorf("picked slot %d out of range", i)
+	}
+	return i, nil
+}
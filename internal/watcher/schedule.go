@@ -0,0 +1,170 @@
+package watcher
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule decides how long the watcher waits between two polls. Rules are
+// evaluated in order against the wall clock of the request's timezone, the
+// first window containing the current time wins. Outside of all windows
+// Default is used, or the PollMinSec/PollMaxSec jitter if Default is zero.
+type Schedule struct {
+	Rules   []ScheduleRule
+	Default time.Duration
+}
+
+// ScheduleRule is a daily window [From, To) in minutes after midnight.
+// Windows with To <= From wrap around midnight (e.g. 22:00–06:00).
+type ScheduleRule struct {
+	From  int
+	To    int
+	Every time.Duration
+	Pause bool
+}
+
+func (r ScheduleRule) contains(min int) bool {
+	if r.From < r.To {
+		return min >= r.From && min < r.To
+	}
+	return min >= r.From || min < r.To
+}
+
+func (r ScheduleRule) String() string {
+	what := r.Every.String()
+	if r.Pause {
+		what = "pause"
+	}
+	return fmt.Sprintf("%s-%s=%s", clock(r.From), clock(r.To), what)
+}
+
+// ParseSchedule reads rules like "06:55-08:30=20s;22:00-06:00=pause;*=5m".
+// "*" sets the default interval used outside of all windows.
+func ParseSchedule(s string) (Schedule, error) {
+	var sch Schedule
+	for _, part := range strings.Split(s, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		win, what, ok := strings.Cut(part, "=")
+		if !ok {
+			return Schedule{}, fmt.Errorf("schedule rule %q: missing '='", part)
+		}
+		win, what = strings.TrimSpace(win), strings.TrimSpace(what)
+
+		if win == "*" {
+			d, err := time.ParseDuration(what)
+			if err != nil || d <= 0 {
+				return Schedule{}, fmt.Errorf("schedule rule %q: invalid interval", part)
+			}
+			sch.Default = d
+			continue
+		}
+
+		from, to, ok := strings.Cut(win, "-")
+		if !ok {
+			return Schedule{}, fmt.Errorf("schedule rule %q: window must be HH:MM-HH:MM", part)
+		}
+		var r ScheduleRule
+		var err error
+		if r.From, err = parseClock(from); err != nil {
+			return Schedule{}, fmt.Errorf("schedule rule %q: %w", part, err)
+		}
+		if r.To, err = parseClock(to); err != nil {
+			return Schedule{}, fmt.Errorf("schedule rule %q: %w", part, err)
+		}
+		if r.From == r.To {
+			return Schedule{}, fmt.Errorf("schedule rule %q: empty window", part)
+		}
+		if what == "pause" {
+			r.Pause = true
+		} else {
+			if r.Every, err = time.ParseDuration(what); err != nil || r.Every <= 0 {
+				return Schedule{}, fmt.Errorf("schedule rule %q: invalid interval", part)
+			}
+		}
+		sch.Rules = append(sch.Rules, r)
+	}
+	return sch, nil
+}
+
+func parseClock(s string) (int, error) {
+	hh, mm, ok := strings.Cut(strings.TrimSpace(s), ":")
+	h, herr := strconv.Atoi(hh)
+	m, merr := strconv.Atoi(mm)
+	if !ok || herr != nil || merr != nil || h < 0 || h > 24 || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return (h*60 + m) % (24 * 60), nil
+}
+
+func clock(min int) string { return fmt.Sprintf("%02d:%02d", min/60, min%60) }
+
+func minuteOfDay(t time.Time) int { return t.Hour()*60 + t.Minute() }
+
+// until returns the duration from now until the wall clock next reads min.
+func until(now time.Time, min int) time.Duration {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	next := midnight.Add(time.Duration(min) * time.Minute)
+	if !next.After(now) {
+		next = time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location()).
+			Add(time.Duration(min) * time.Minute)
+	}
+	return next.Sub(now)
+}
+
+func (s Schedule) match(now time.Time) (ScheduleRule, bool) {
+	m := minuteOfDay(now)
+	for _, r := range s.Rules {
+		if r.contains(m) {
+			return r, true
+		}
+	}
+	return ScheduleRule{}, false
+}
+
+// pausedFor reports how long polling is suspended at now.
+func (s Schedule) pausedFor(now time.Time) (time.Duration, bool) {
+	r, ok := s.match(now)
+	if !ok || !r.Pause {
+		return 0, false
+	}
+	return until(now, r.To), true
+}
+
+// next returns the wait before the following poll. fallback supplies the
+// interval outside of all windows when no Default is configured. The wait is
+// cut short when another window starts earlier, so a fast window is never
+// entered late.
+func (s Schedule) next(now time.Time, fallback func() time.Duration) time.Duration {
+	var d time.Duration
+	cur, ok := s.match(now)
+	switch {
+	case ok && cur.Pause:
+		return until(now, cur.To)
+	case ok:
+		d = cur.Every
+	case s.Default > 0:
+		d = s.Default
+	default:
+		d = fallback()
+	}
+
+	for _, r := range s.Rules {
+		if ok && r == cur {
+			continue
+		}
+		if w := until(now, r.From); w < d {
+			d = w
+		}
+	}
+	if ok {
+		if w := until(now, cur.To); w < d {
+			d = w
+		}
+	}
+	return d
+}
@@ -0,0 +1,147 @@
+package watcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/browser"
+)
+
+const (
+	maxPollRecords = 20000
+	bucketMinutes  = 15
+	// minAdaptive is the shortest interval Adaptive ever waits, whatever
+	// Min says.
+	minAdaptive = 5 * time.Second
+)
+
+// PollRecord is the outcome of a single ListSlots call.
+type PollRecord struct {
+	At    time.Time `json:"at"`
+	Slots int       `json:"slots"`
+	New   int       `json:"new"`
+}
+
+// Stats records every poll and learns at which times of the day new slots
+// usually appear. It is persisted as JSON at path after each record.
+type Stats struct {
+	mu   sync.Mutex
+	path string
+
+	Polls []PollRecord `json:"polls"`
+	// Last holds the slot start times of the previous poll, so slots can be
+	// told apart from the ones that were already listed.
+	Last []time.Time `json:"last"`
+}
+
+func LoadStats(path string) (*Stats, error) {
+	st := &Stats{path: path}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("stats read: %w", err)
+	}
+	if err := json.Unmarshal(b, st); err != nil {
+		return nil, fmt.Errorf("stats parse: %w", err)
+	}
+	return st, nil
+}
+
+// Record stores the slots returned by one poll and returns how many of them
+// were not listed by the previous poll.
+func (s *Stats) Record(at time.Time, slots []browser.Slot) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev := make(map[int64]bool, len(s.Last))
+	for _, t := range s.Last {
+		prev[t.Unix()] = true
+	}
+	cur := make([]time.Time, 0, len(slots))
+	fresh := 0
+	for _, sl := range slots {
+		if !prev[sl.Start.Unix()] {
+			fresh++
+		}
+		cur = append(cur, sl.Start)
+	}
+
+	s.Last = cur
+	s.Polls = append(s.Polls, PollRecord{At: at, Slots: len(slots), New: fresh})
+	if len(s.Polls) > maxPollRecords {
+		s.Polls = s.Polls[len(s.Polls)-maxPollRecords:]
+	}
+	return fresh, s.save()
+}
+
+func (s *Stats) save() error {
+	if s.path == "" {
+		return nil
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("stats write: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return fmt.Errorf("stats write: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// heat returns 0..1 describing how often new slots appeared around the wall
+// clock time of now, relative to the busiest time of the day. The following
+// bucket is considered as well so polling speeds up shortly before a release.
+func (s *Stats) heat(now time.Time) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	const buckets = 24 * 60 / bucketMinutes
+	var counts [buckets]int
+	// The first poll finds every slot "new", which says nothing about releases.
+	for i, p := range s.Polls {
+		if i == 0 || p.New == 0 {
+			continue
+		}
+		counts[minuteOfDay(p.At.In(now.Location()))/bucketMinutes]++
+	}
+
+	busiest := 0
+	for _, c := range counts {
+		busiest = max(busiest, c)
+	}
+	if busiest == 0 {
+		return 0
+	}
+	b := minuteOfDay(now) / bucketMinutes
+	return float64(max(counts[b], counts[(b+1)%buckets])) / float64(busiest)
+}
+
+// Adaptive speeds up polling in the time windows where Stats has seen new
+// slots appear. The learned interval always stays within [Min, Max].
+type Adaptive struct {
+	Stats *Stats
+	Min   time.Duration
+	Max   time.Duration
+}
+
+func (a Adaptive) interval(now time.Time) time.Duration {
+	lo, hi := a.Min, a.Max
+	if hi < lo {
+		lo, hi = hi, lo
+	}
+	lo = max(lo, minAdaptive)
+	hi = max(hi, lo)
+	h := a.Stats.heat(now)
+	return hi - time.Duration(h*float64(hi-lo))
+}
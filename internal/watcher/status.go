@@ -0,0 +1,151 @@
+package watcher
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/browser"
+)
+
+type State string
+
+const (
+	StateStarting   State = "starting"
+	StateNavigating State = "navigating"
+	StateListing    State = "listing"
+	StatePicking    State = "picking"
+	// StateAwaitingCode waits for the verification code until CodeDeadline.
+	StateAwaitingCode State = "awaiting_code"
+	StateBooking      State = "booking"
+	StateSleeping     State = "sleeping"
+	StatePaused       State = "paused"
+	StateBooked       State = "booked"
+	StateStopped      State = "stopped"
+)
+
+const maxErrorHistory = 20
+
+// SlotStatus is a slot listed by the last poll.
+type SlotStatus struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end,omitzero"`
+	Capacity int       `json:"capacity,omitempty"`
+	Resource string    `json:"resource,omitempty"`
+	Match    bool      `json:"match"`
+	// Ref locates the slot on the site again.
+	Ref browser.Locator `json:"ref,omitzero"`
+}
+
+func slotStatus(s browser.Slot, match bool) SlotStatus {
+	return SlotStatus{Start: s.Start, End: s.End, Capacity: s.Capacity, Resource: s.Resource, Match: match, Ref: s.Ref}
+}
+
+type ErrorEntry struct {
+	At  time.Time `json:"at"`
+	Err string    `json:"err"`
+}
+
+// StatusSnapshot is a copy of the watcher status at one point in time.
+type StatusSnapshot struct {
+	State State     `json:"state"`
+	Since time.Time `json:"since"`
+	Poll  int       `json:"poll"`
+	// LastPoll is the last time slots could be listed.
+	LastPoll time.Time `json:"last_poll,omitzero"`
+	NextPoll time.Time `json:"next_poll,omitzero"`
+	// CodeDeadline is set while the state is StateAwaitingCode.
+	CodeDeadline time.Time `json:"code_deadline,omitzero"`
+	LastError    string    `json:"last_error,omitempty"`
+	LastErrorAt  time.Time `json:"last_error_at,omitzero"`
+	// Slots are the slots listed by the last successful poll.
+	Slots []SlotStatus `json:"slots"`
+	// Errors holds the most recent errors, oldest first.
+	Errors []ErrorEntry `json:"errors"`
+}
+
+// Status tracks what a running watcher is doing. It is updated by Run and
+// may be read concurrently. All methods are safe to call on a nil *Status.
+type Status struct {
+	mu sync.Mutex
+	s  StatusSnapshot
+}
+
+func NewStatus() *Status {
+	return &Status{s: StatusSnapshot{State: StateStarting, Since: time.Now()}}
+}
+
+func (st *Status) Snapshot() StatusSnapshot {
+	if st == nil {
+		return StatusSnapshot{}
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	s := st.s
+	s.Slots = append([]SlotStatus(nil), st.s.Slots...)
+	s.Errors = append([]ErrorEntry(nil), st.s.Errors...)
+	return s
+}
+
+// OnEvent implements Observer.
+func (st *Status) OnEvent(e Event) {
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	switch e.Kind {
+	case EventPollStarted:
+		st.setState(StateNavigating, e.At)
+		st.s.Poll = e.Poll
+	case EventFlowReady:
+		st.setState(StateListing, e.At)
+	case EventSlotsListed:
+		st.s.LastPoll = e.At
+		st.s.Slots = e.Slots
+	case EventPickRequested:
+		st.setState(StatePicking, e.At)
+	case EventCodeRequested:
+		st.setState(StateAwaitingCode, e.At)
+		st.s.CodeDeadline = e.Until
+	case EventSlotMatched, EventBookingStep:
+		st.setState(StateBooking, e.At)
+	case EventBooked:
+		st.setState(StateBooked, e.At)
+	case EventError:
+		if e.Err == nil {
+			return
+		}
+		st.s.LastError = e.Err.Error()
+		st.s.LastErrorAt = e.At
+		st.s.Errors = append(st.s.Errors, ErrorEntry{At: e.At, Err: st.s.LastError})
+		if len(st.s.Errors) > maxErrorHistory {
+			st.s.Errors = st.s.Errors[len(st.s.Errors)-maxErrorHistory:]
+		}
+	case EventSleeping:
+		state := StateSleeping
+		if e.Paused {
+			state = StatePaused
+		}
+		st.setState(state, e.At)
+		st.s.NextPoll = e.Until
+	case EventStopped:
+		// A booked watcher stays booked.
+		if st.s.State != StateBooked {
+			st.setState(StateStopped, e.At)
+		}
+	}
+}
+
+func (st *Status) setState(state State, at time.Time) {
+	if st.s.State != state {
+		st.s.State = state
+		st.s.Since = at
+	}
+	if state != StateSleeping && state != StatePaused {
+		st.s.NextPoll = time.Time{}
+	}
+	if state != StateAwaitingCode {
+		st.s.CodeDeadline = time.Time{}
+	}
+}
@@ -2,12 +2,16 @@ package watcher
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
 	"math/rand"
+	"strings"
 	"time"
 
 	"github.com/mlentzler/ZulassungsstelleBot/internal/browser"
 	"github.com/mlentzler/ZulassungsstelleBot/internal/domain"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/history"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/logging"
 )
 
 type Config struct {
@@ -15,21 +19,75 @@ type Config struct {
 	Headless   bool
 	PollMinSec int
 	PollMaxSec int
+	Schedule   Schedule
+	// Adaptive is optional; without Stats polls are neither recorded nor sped up.
+	Adaptive Adaptive
+	// History receives a snapshot of every successful ListSlots call if set.
+	History *history.Store
+	// Logger defaults to slog.Default(). The personal data of the request is
+	// masked in its output unless Unredacted is set.
+	Logger     *slog.Logger
+	Unredacted bool
+	// Observers receive every event of Run, e.g. a *Status or metrics.
+	Observers []Observer
+	// Control is optional and lets a user interface pause or hurry Run.
+	Control *Control
+	// Picker is optional. If set, a human chooses which matching slot to
+	// book, otherwise the first matching slot is booked.
+	Picker Picker
+	// PickTimeout bounds the wait for the Picker, two minutes by default.
+	PickTimeout time.Duration
+	// WholeSlot only matches slots that also end inside the availability
+	// window, where the site shows an end time.
+	WholeSlot bool
+	// Booking is passed to BookSlot. It belongs to the request, the control
+	// API sets it per watch.
+	Booking browser.BookingOptions
+	// Code supplies the verification code if the site emails one before
+	// the booking is final. CodeTimeout bounds the wait, ten minutes by
+	// default.
+	Code        CodeSource
+	CodeTimeout time.Duration
+}
+
+// nextPoll returns how long to wait before the next poll at the wall clock of loc.
+func (c Config) nextPoll(loc *time.Location) time.Duration {
+	now := time.Now().In(loc)
+	if d, paused := c.Schedule.pausedFor(now); paused {
+		return d
+	}
+	d := c.Schedule.next(now, func() time.Duration {
+		return jitter(c.PollMinSec, c.PollMaxSec)
+	})
+	if c.Adaptive.Stats != nil {
+		d = min(d, c.Adaptive.interval(now))
+	}
+	return d
 }
 
 func Run(ctx context.Context, drv browser.Driver, cfg Config, req domain.BookingRequest) error {
 	loc, _ := time.LoadLocation(req.TZ)
-	form := map[string]string{
-		"name":    req.Name,
-		"email":   req.Email,
-		"telefon": req.Phone,
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if !cfg.Unredacted {
+		logger = logging.Redact(logger, req.PersonalData.Values()...)
 	}
+	logger = logger.With("menu", strings.Join(req.Menu.Path, " > "))
+
+	ctl := cfg.Control
+	emit := observers(cfg.Observers).emit
+	defer emit(Event{Kind: EventStopped})
 
 	if err := drv.Open(ctx); err != nil {
+		emit(Event{Kind: EventError, Step: StepOpen, Err: err})
 		return err
 	}
 	defer drv.Close(ctx)
 
+	poll := 0
+	forced := false
 	for {
 		select {
 		case <-ctx.Done():
@@ -37,13 +95,37 @@ func Run(ctx context.Context, drv browser.Driver, cfg Config, req domain.Booking
 		default:
 		}
 
+		if ctl.Paused() {
+			logger.Info("polling paused by user")
+			emit(Event{Kind: EventSleeping, Poll: poll, Paused: true})
+			if !ctl.waitResumed(ctx) {
+				return ctx.Err()
+			}
+			logger.Info("polling resumed by user")
+		}
+
+		if d, paused := cfg.Schedule.pausedFor(time.Now().In(loc)); paused && !forced {
+			until := time.Now().Add(d)
+			logger.Info("polling paused", "until", until.In(loc).Format("15:04"))
+			ctl.arm()
+			emit(Event{Kind: EventSleeping, Poll: poll, Until: until, Paused: true})
+			forced = ctl.sleep(ctx, d)
+			continue
+		}
+		forced = false
+
+		poll++
+		log := logger.With("poll", poll)
+		started := time.Now()
+		emit(Event{Kind: EventPollStarted, Poll: poll})
+
 		if err := drv.StartFlow(ctx, cfg.BaseURL, req.Menu.Path, req.Menu.Selectors); err != nil {
-			// WICHTIG: Log mit selector/titel
-			// (import "log")
-			log.Printf("StartFlow error: %v", err)
-			sleep(ctx, jitter(cfg.PollMinSec, cfg.PollMaxSec))
+			log.Warn("StartFlow failed", "step", StepStartFlow, "err", err)
+			emit(Event{Kind: EventError, Poll: poll, Step: StepStartFlow, Err: err, Elapsed: time.Since(started)})
+			wait(ctx, log, emit, ctl, poll, cfg.nextPoll(loc))
 			continue
 		}
+		emit(Event{Kind: EventFlowReady, Poll: poll})
 
 		if req.Avail.Kind == domain.AvailOneOff && req.Avail.OneOff != nil {
 			dt, _ := time.ParseInLocation("2006-01-02", req.Avail.OneOff.DateISO, loc)
@@ -51,45 +133,139 @@ func Run(ctx context.Context, drv browser.Driver, cfg Config, req domain.Booking
 		}
 
 		slots, err := drv.ListSlots(ctx)
-		if err != nil || len(slots) == 0 {
-			sleep(ctx, jitter(cfg.PollMinSec, cfg.PollMaxSec))
+		if err != nil {
+			log.Warn("ListSlots failed", "step", StepListSlots, "err", err)
+			emit(Event{Kind: EventError, Poll: poll, Step: StepListSlots, Err: err, Elapsed: time.Since(started)})
+			wait(ctx, log, emit, ctl, poll, cfg.nextPoll(loc))
 			continue
 		}
+		log.Info("slots listed", "count", len(slots))
 
-		var chosen *browser.Slot
-		for i := range slots {
-			if browser.SlotMatches(req.Avail, slots[i].Start, loc) {
-				chosen = &slots[i]
-				break
+		if cfg.Adaptive.Stats != nil {
+			if fresh, err := cfg.Adaptive.Stats.Record(time.Now(), slots); err != nil {
+				log.Error("saving stats failed", "err", err)
+				emit(Event{Kind: EventError, Poll: poll, Step: StepStats, Err: err})
+			} else if fresh > 0 {
+				log.Info("new slots since last poll", "count", fresh)
+			}
+		}
+		if cfg.History != nil {
+			snap := history.Snapshot{At: time.Now(), Menu: req.Menu.Path}
+			minutes := make([]int, len(slots))
+			known := false
+			for i, sl := range slots {
+				snap.Slots = append(snap.Slots, sl.Start)
+				minutes[i] = int(sl.Duration() / time.Minute)
+				known = known || minutes[i] > 0
+			}
+			if known {
+				snap.Minutes = minutes
+			}
+			if err := cfg.History.Append(snap); err != nil {
+				log.Error("saving history failed", "err", err)
+				emit(Event{Kind: EventError, Poll: poll, Step: StepHistory, Err: err})
 			}
 		}
 
-		if chosen == nil {
-			sleep(ctx, jitter(cfg.PollMinSec, cfg.PollMaxSec))
-			continue
+		var matches []browser.Slot
+		seen := make([]SlotStatus, 0, len(slots))
+		for i := range slots {
+			ok := browser.SlotMatches(req.Avail, slots[i], loc, cfg.WholeSlot)
+			if ok {
+				matches = append(matches, slots[i])
+			}
+			seen = append(seen, slotStatus(slots[i], ok))
 		}
+		emit(Event{Kind: EventSlotsListed, Poll: poll, Slots: seen, Elapsed: time.Since(started)})
 
-		if err := drv.BookSlot(ctx, *chosen, form); err != nil {
-			sleep(ctx, 3*time.Second)
+		if len(matches) == 0 {
+			log.Debug("no matching slot")
+			wait(ctx, log, emit, ctl, poll, cfg.nextPoll(loc))
 			continue
 		}
 
-		if err := drv.FillAndContinue(ctx, form); err != nil {
-			log.Printf("FillAndContinue failed: %v", err)
-			sleep(ctx, 3*time.Second)
-			continue
+		chosen := &matches[0]
+		if cfg.Picker != nil {
+			offered := make([]SlotStatus, len(matches))
+			for i, m := range matches {
+				offered[i] = slotStatus(m, true)
+			}
+			log.Info("waiting for slot choice", "count", len(matches))
+			emit(Event{Kind: EventPickRequested, Poll: poll, Slots: offered})
+			i, err := cfg.pick(ctx, matches)
+			if err != nil {
+				log.Info("no slot picked", "err", err)
+				emit(Event{Kind: EventError, Poll: poll, Step: StepPick, Err: err})
+				wait(ctx, log, emit, ctl, poll, cfg.nextPoll(loc))
+				continue
+			}
+			chosen = &matches[i]
 		}
+		log = log.With("slot", chosen.Start.In(loc).Format(time.RFC3339))
+		log.Info("matching slot found")
+		emit(Event{Kind: EventSlotMatched, Poll: poll, Slot: chosen.Start})
 
-		if err := drv.ConfirmBooking(ctx); err != nil {
-			log.Printf("ConfirmBooking failed: %v", err)
-			sleep(ctx, 3*time.Second)
+		// The site may ask for an emailed code after the form or after the
+		// confirmation.
+		var submitted time.Time
+		verify := func(confirmed bool) func() error {
+			return func() error {
+				return cfg.verify(ctx, drv, req, submitted, confirmed, log, emit, poll, chosen.Start)
+			}
+		}
+		// Once confirmed, a failure must not send Run back to polling.
+		steps := []struct {
+			name      string
+			run       func() error
+			confirmed bool
+		}{
+			{StepBookSlot, func() error { return drv.BookSlot(ctx, *chosen, cfg.Booking) }, false},
+			{StepFillAndContinue, func() error {
+				submitted = time.Now()
+				return drv.FillAndContinue(ctx, req.PersonalData, req.Menu.Fields)
+			}, false},
+			{StepVerify, verify(false), false},
+			{StepConfirmBooking, func() error { return drv.ConfirmBooking(ctx) }, false},
+			{StepVerify, verify(true), true},
+		}
+		failed := false
+		for _, step := range steps {
+			if step.name != StepVerify {
+				emit(Event{Kind: EventBookingStep, Poll: poll, Slot: chosen.Start, Step: step.name})
+			}
+			if err := step.run(); err != nil {
+				log.Warn(step.name+" failed", "step", step.name, "err", err)
+				emit(Event{Kind: EventError, Poll: poll, Slot: chosen.Start, Step: step.name, Err: err})
+				if step.confirmed {
+					return fmt.Errorf("%w: %w", ErrUnverified, err)
+				}
+				failed = true
+				break
+			}
+			if step.name == StepConfirmBooking {
+				emit(Event{Kind: EventConfirmed, Poll: poll, Slot: chosen.Start})
+			}
+		}
+		if failed {
+			wait(ctx, log, emit, ctl, poll, 3*time.Second)
 			continue
 		}
 
+		log.Info("slot booked")
+		emit(Event{Kind: EventBooked, Poll: poll, Slot: chosen.Start})
 		return nil
 	}
 }
 
+// wait sleeps until the next poll and logs when that will be.
+func wait(ctx context.Context, log *slog.Logger, emit func(Event), ctl *Control, poll int, d time.Duration) {
+	next := time.Now().Add(d)
+	log.Debug("sleeping", "next_poll", next.Format("15:04:05"))
+	ctl.arm()
+	emit(Event{Kind: EventSleeping, Poll: poll, Until: next})
+	ctl.sleep(ctx, d)
+}
+
 func jitter(minSec, maxSec int) time.Duration {
 	if minSec <= 0 {
 		minSec = 20
@@ -100,12 +276,3 @@ func jitter(minSec, maxSec int) time.Duration {
 	sec := rand.Intn(maxSec-minSec+1) + minSec
 	return time.Duration(sec) * time.Second
 }
-
-func sleep(ctx context.Context, d time.Duration) {
-	t := time.NewTimer(d)
-	defer t.Stop()
-	select {
-	case <-ctx.Done():
-	case <-t.C:
-	}
-}
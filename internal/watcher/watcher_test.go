@@ -0,0 +1,304 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mlentzler/ZulassungsstelleBot/internal/browser"
+	"github.com/mlentzler/ZulassungsstelleBot/internal/domain"
+)
+
+// fakeDriver lists the same slots on every poll. The errors in startErrs,
+// listErrs and bookErrs are returned by the first calls of StartFlow,
+// ListSlots and BookSlot, one per call.
+type fakeDriver struct {
+	slots     []browser.Slot
+	openErr   error
+	startErrs []error
+	listErrs  []error
+	bookErrs  []error
+	calls     []string
+}
+
+// next pops the first error of errs.
+func next(errs *[]error) error {
+	if len(*errs) == 0 {
+		return nil
+	}
+	err := (*errs)[0]
+	*errs = (*errs)[1:]
+	return err
+}
+
+func (f *fakeDriver) Open(context.Context) error {
+	f.calls = append(f.calls, "Open")
+	return f.openErr
+}
+
+func (f *fakeDriver) Close(context.Context) error {
+	f.calls = append(f.calls, "Close")
+	return nil
+}
+
+func (f *fakeDriver) StartFlow(context.Context, string, []string, []string) error {
+	f.calls = append(f.calls, StepStartFlow)
+	return next(&f.startErrs)
+}
+
+func (f *fakeDriver) PickDate(context.Context, time.Time) error {
+	f.calls = append(f.calls, "PickDate")
+	return nil
+}
+
+func (f *fakeDriver) ListSlots(context.Context) ([]browser.Slot, error) {
+	f.calls = append(f.calls, StepListSlots)
+	if err := next(&f.listErrs); err != nil {
+		return nil, err
+	}
+	return f.slots, nil
+}
+
+func (f *fakeDriver) BookSlot(context.Context, browser.Slot, browser.BookingOptions) error {
+	f.calls = append(f.calls, StepBookSlot)
+	return next(&f.bookErrs)
+}
+
+func (f *fakeDriver) FillAndContinue(context.Context, domain.PersonalData, []domain.FormField) error {
+	f.calls = append(f.calls, StepFillAndContinue)
+	return nil
+}
+
+func (f *fakeDriver) ConfirmBooking(context.Context) error {
+	f.calls = append(f.calls, StepConfirmBooking)
+	return nil
+}
+
+func (f *fakeDriver) NeedsCode(context.Context) (bool, error) { return false, nil }
+
+func (f *fakeDriver) EnterCode(context.Context, string) error {
+	f.calls = append(f.calls, "EnterCode")
+	return nil
+}
+
+// testRun runs the watcher for a request matching Tuesdays 8 to 12 o'clock
+// and returns the events as "kind" or "kind:step". onEvent is called for
+// every event before it is recorded.
+func testRun(t *testing.T, ctx context.Context, drv *fakeDriver, cfg Config, onEvent func(Event)) ([]string, error) {
+	t.Helper()
+	req := domain.BookingRequest{
+		PersonalData: domain.PersonalData{Name: "Erika Mustermann", Email: "erika@example.org"},
+		Menu:         domain.MenuChoice{Path: []string{"Zulassung"}},
+		Avail: domain.Availability{
+			Kind:      domain.AvailRecurring,
+			Recurring: &domain.Recurring{Days: []domain.DayWindow{{Weekday: "DI", FromHour: 8, ToHour: 12}}},
+		},
+		TZ: "Europe/Berlin",
+	}
+	var events []string
+	cfg.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg.Observers = append(cfg.Observers, ObserverFunc(func(e Event) {
+		if onEvent != nil {
+			onEvent(e)
+		}
+		s := string(e.Kind)
+		if e.Step != "" {
+			s += ":" + e.Step
+		}
+		events = append(events, s)
+	}))
+	err := Run(ctx, drv, cfg, req)
+	return events, err
+}
+
+func berlin(t *testing.T, s string) time.Time {
+	t.Helper()
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := time.ParseInLocation("2006-01-02 15:04", s, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+var bookedEvents = []string{
+	"poll_started",
+	"flow_ready",
+	"slots_listed",
+	"slot_matched",
+	"booking_step:BookSlot",
+	"booking_step:FillAndContinue",
+	"booking_step:ConfirmBooking",
+	"confirmed",
+	"booked",
+	"stopped",
+}
+
+func TestRunBooks(t *testing.T) {
+	slot := berlin(t, "2025-10-21 09:30")
+	drv := &fakeDriver{slots: []browser.Slot{
+		{Start: berlin(t, "2025-10-21 07:30")},
+		{Start: slot},
+	}}
+	var matched time.Time
+	got, err := testRun(t, context.Background(), drv, Config{}, func(e Event) {
+		if e.Kind == EventSlotMatched {
+			matched = e.Slot
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, bookedEvents) {
+		t.Fatalf("events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(bookedEvents, "\n"))
+	}
+	if !matched.Equal(slot) {
+		t.Errorf("matched %v, want %v", matched, slot)
+	}
+	want := []string{"Open", StepStartFlow, StepListSlots, StepBookSlot, StepFillAndContinue, StepConfirmBooking, "Close"}
+	if !slices.Equal(drv.calls, want) {
+		t.Errorf("calls = %v, want %v", drv.calls, want)
+	}
+}
+
+func TestRunStartFlowError(t *testing.T) {
+	drv := &fakeDriver{
+		slots:     []browser.Slot{{Start: berlin(t, "2025-10-21 09:30")}},
+		startErrs: []error{errors.New("menu not found")},
+	}
+	ctl := NewControl()
+	var sleeps int
+	got, err := testRun(t, context.Background(), drv, Config{Control: ctl}, func(e Event) {
+		if e.Kind == EventError && e.Err == nil {
+			t.Errorf("error event without Err: %+v", e)
+		}
+		if e.Kind == EventSleeping {
+			sleeps++
+			if e.Until.IsZero() {
+				t.Errorf("sleeping without Until: %+v", e)
+			}
+			ctl.PollNow()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]string{"poll_started", "error:StartFlow", "sleeping"}, bookedEvents...)
+	if !slices.Equal(got, want) {
+		t.Fatalf("events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if sleeps != 1 {
+		t.Errorf("slept %d times, want 1", sleeps)
+	}
+}
+
+// pollOnSleep returns an observer that calls PollNow on every sleep until
+// the n-th, where it calls cancel.
+func pollOnSleep(t *testing.T, ctl *Control, n int, cancel func()) func(Event) {
+	sleeps := 0
+	return func(e Event) {
+		if e.Kind != EventSleeping {
+			return
+		}
+		if e.Until.IsZero() {
+			t.Errorf("sleeping without Until: %+v", e)
+		}
+		sleeps++
+		if sleeps == n {
+			cancel()
+			return
+		}
+		ctl.PollNow()
+	}
+}
+
+func TestRunNoMatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	drv := &fakeDriver{slots: []browser.Slot{{Start: berlin(t, "2025-10-21 07:30")}}}
+	ctl := NewControl()
+	got, err := testRun(t, ctx, drv, Config{Control: ctl}, pollOnSleep(t, ctl, 2, cancel))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	poll := []string{"poll_started", "flow_ready", "slots_listed", "sleeping"}
+	want := append(append(slices.Clone(poll), poll...), "stopped")
+	if !slices.Equal(got, want) {
+		t.Fatalf("events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRunListSlotsError(t *testing.T) {
+	drv := &fakeDriver{
+		slots:    []browser.Slot{{Start: berlin(t, "2025-10-21 09:30")}},
+		listErrs: []error{errors.New("calendar not loaded")},
+	}
+	ctl := NewControl()
+	got, err := testRun(t, context.Background(), drv, Config{Control: ctl}, pollOnSleep(t, ctl, 0, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]string{"poll_started", "flow_ready", "error:ListSlots", "sleeping"}, bookedEvents...)
+	if !slices.Equal(got, want) {
+		t.Fatalf("events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRunBookingStepError(t *testing.T) {
+	drv := &fakeDriver{
+		slots:    []browser.Slot{{Start: berlin(t, "2025-10-21 09:30")}},
+		bookErrs: []error{errors.New("slot taken")},
+	}
+	ctl := NewControl()
+	got, err := testRun(t, context.Background(), drv, Config{Control: ctl}, pollOnSleep(t, ctl, 0, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]string{
+		"poll_started", "flow_ready", "slots_listed", "slot_matched",
+		"booking_step:BookSlot", "error:BookSlot", "sleeping",
+	}, bookedEvents...)
+	if !slices.Equal(got, want) {
+		t.Fatalf("events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRunStoppedOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	drv := &fakeDriver{startErrs: []error{errors.New("timeout")}}
+	got, err := testRun(t, ctx, drv, Config{}, func(e Event) {
+		if e.Kind == EventSleeping {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	want := []string{"poll_started", "error:StartFlow", "sleeping", "stopped"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	if drv.calls[len(drv.calls)-1] != "Close" {
+		t.Errorf("driver not closed: %v", drv.calls)
+	}
+}
+
+func TestRunOpenError(t *testing.T) {
+	drv := &fakeDriver{openErr: errors.New("no browser")}
+	got, err := testRun(t, context.Background(), drv, Config{}, nil)
+	if !errors.Is(err, drv.openErr) {
+		t.Fatalf("err = %v", err)
+	}
+	if want := []string{"error:Open", "stopped"}; !slices.Equal(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,524 @@
+"use strict";
+
+const app = document.getElementById("app");
+const weekdays = [["MO", "Montag"], ["DI", "Dienstag"], ["MI", "Mittwoch"], ["DO", "Donnerstag"], ["FR", "Freitag"]];
+const monthNames = ["Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"];
+const stepNames = ["Person", "Leistung", "Angaben", "Verfügbarkeit", "Prüfen"];
+
+let menu = null;
+let tz = "";
+let form = null;
+let timer = null;
+
+function esc(s) {
+  return String(s).replace(/[&<>"']/g, c => ({ "&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;", "'": "&#39;" }[c]));
+}
+
+// api calls the control API. With API_TOKEN set on the server the token is
+// asked for once and kept in this browser.
+async function api(method, path, body) {
+  const headers = body ? { "Content-Type": "application/json" } : {};
+  const token = localStorage.getItem("apiToken");
+  if (token) headers["Authorization"] = "Bearer " + token;
+  const res = await fetch(path, {
+    method,
+    headers,
+    body: body ? JSON.stringify(body) : undefined,
+  });
+  if (res.status === 401) {
+    const t = prompt("API-Token:");
+    if (t) {
+      localStorage.setItem("apiToken", t.trim());
+      return api(method, path, body);
+    }
+  }
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error(data.error || res.statusText);
+  return data;
+}
+
+function newForm() {
+  return {
+    step: 0,
+    name: "", email: "", phone: "",
+    stack: [], path: [], selectors: [],
+    fields: [], extra: {},
+    kind: "oneoff",
+    date: "", from: 8, to: 12,
+    month: null,
+    days: {},
+    error: "",
+  };
+}
+
+function pad(n) { return String(n).padStart(2, "0"); }
+
+function fmtTime(iso, withDate) {
+  if (!iso) return "–";
+  const opts = { timeZone: tz || undefined, hour: "2-digit", minute: "2-digit", second: "2-digit" };
+  if (withDate) Object.assign(opts, { weekday: "short", day: "2-digit", month: "2-digit", year: "numeric", second: undefined });
+  return new Date(iso).toLocaleString("de-DE", opts);
+}
+
+function slotText(sl) {
+  let t = fmtTime(sl.start, true);
+  if (sl.end) t += "–" + new Date(sl.end).toLocaleTimeString("de-DE", { timeZone: tz || undefined, hour: "2-digit", minute: "2-digit" });
+  if (sl.capacity) t += ` · ${sl.capacity} frei`;
+  if (sl.resource) t += ` · ${sl.resource}`;
+  return t;
+}
+
+// Routing: #/ lists watches, #/new is the form, #/watch/{id} the status page.
+async function route() {
+  clearInterval(timer);
+  timer = null;
+  if (!menu) {
+    try {
+      const m = await api("GET", "/api/menu");
+      menu = m.menu;
+      tz = m.tz;
+    } catch (e) {
+      app.innerHTML = `<p class="error">Menü konnte nicht geladen werden: ${esc(e.message)}</p>`;
+      return;
+    }
+  }
+  const h = location.hash.replace(/^#/, "") || "/";
+  const m = h.match(/^\/watch\/(.+)$/);
+  if (m) return showWatch(decodeURIComponent(m[1]));
+  if (h === "/new") {
+    if (!form) form = newForm();
+    return renderForm();
+  }
+  return showList();
+}
+
+async function showList() {
+  let watches = [];
+  try {
+    watches = await api("GET", "/api/watches");
+  } catch (e) {
+    app.innerHTML = `<p class="error">${esc(e.message)}</p>`;
+    return;
+  }
+  let html = `<h2>Terminsuchen</h2>`;
+  if (watches.length === 0) {
+    html += `<p>Noch keine Terminsuche gestartet.</p>`;
+  } else {
+    html += `<table class="watches"><tr><th>#</th><th>Leistung</th><th>Status</th><th>Ergebnis</th></tr>`;
+    for (const w of watches) {
+      html += `<tr><td><a href="#/watch/${encodeURIComponent(w.id)}">${esc(w.id)}</a></td>
+        <td>${esc((w.menu || []).join(" › "))}</td><td>${esc(stateLine(w))}</td><td>${esc(resultLine(w))}</td></tr>`;
+    }
+    html += `</table>`;
+  }
+  html += `<button class="primary" id="new">Neue Terminsuche</button>`;
+  app.innerHTML = html;
+  document.getElementById("new").onclick = () => {
+    form = newForm();
+    location.hash = "#/new";
+  };
+}
+
+function renderForm() {
+  const f = form;
+  let html = `<div class="steps">` + stepNames.map((s, i) => i === f.step ? `<b>${s}</b>` : s).join(" › ") + `</div>`;
+  html += [personStep, menuStep, fieldsStep, availStep, reviewStep][f.step]();
+  if (f.error) html += `<p class="error">${esc(f.error)}</p>`;
+  app.innerHTML = html;
+  bindForm();
+}
+
+function personStep() {
+  const f = form;
+  return `<h2>Persönliche Daten</h2>
+    <label>Name</label><input type="text" id="name" value="${esc(f.name)}">
+    <label>E-Mail</label><input type="email" id="email" value="${esc(f.email)}">
+    <label>Telefon</label><input type="tel" id="phone" value="${esc(f.phone)}">
+    <div><button class="primary" data-next>Weiter</button></div>`;
+}
+
+function currentNode() {
+  let node = menu;
+  for (const i of form.stack) node = node.children[i];
+  return node;
+}
+
+function menuStep() {
+  const node = currentNode();
+  const crumb = ["Start"].concat(form.stack.reduce((acc, i, n) => {
+    let nd = menu;
+    for (const j of form.stack.slice(0, n + 1)) nd = nd.children[j];
+    acc.push(nd.title);
+    return acc;
+  }, []));
+  let html = `<h2>Leistung wählen</h2><div class="crumb">${esc(crumb.join(" › "))}</div><ul class="menu">`;
+  (node.children || []).forEach((c, i) => {
+    const leaf = !c.children || c.children.length === 0;
+    html += `<li data-menu="${i}" class="${leaf ? "leaf" : ""}">${esc(c.title)}</li>`;
+  });
+  html += `</ul><button data-back>Zurück</button>`;
+  return html;
+}
+
+// fieldsStep asks for the extra fields menu.json declares for the service.
+function fieldsStep() {
+  const f = form;
+  let html = `<h2>Weitere Angaben</h2>`;
+  f.fields.forEach((fd, i) => {
+    const v = f.extra[fd.label] || "";
+    html += `<label>${esc(fd.label)}${fd.optional ? " (optional)" : ""}</label>`;
+    if (fd.type === "select") {
+      html += `<select data-field="${i}"><option value=""></option>` +
+        fd.options.map(o => `<option ${o === v ? "selected" : ""}>${esc(o)}</option>`).join("") + `</select>`;
+    } else if (fd.type === "checkbox") {
+      html += `<input type="checkbox" data-field="${i}" ${v === "ja" ? "checked" : ""}>`;
+    } else if (fd.type === "textarea") {
+      html += `<textarea data-field="${i}" placeholder="${esc(fd.hint || "")}">${esc(v)}</textarea>`;
+    } else {
+      html += `<input type="${fd.type === "number" ? "number" : "text"}" data-field="${i}" value="${esc(v)}" placeholder="${esc(fd.hint || "")}">`;
+    }
+  });
+  html += `<div><button data-back>Zurück</button><button class="primary" data-next>Weiter</button></div>`;
+  return html;
+}
+
+function availStep() {
+  const f = form;
+  let html = `<h2>Verfügbarkeit</h2>
+    <label><input type="radio" name="kind" value="oneoff" ${f.kind === "oneoff" ? "checked" : ""}> Einmaliger Termin</label>
+    <label><input type="radio" name="kind" value="recurring" ${f.kind === "recurring" ? "checked" : ""}> Wiederkehrend (Wochentage)</label>`;
+  if (f.kind === "oneoff") {
+    html += calendar() + `<div>Datum: <b>${f.date ? esc(f.date.split("-").reverse().join(".")) : "–"}</b></div>
+      <label>Uhrzeit</label>
+      von <input type="number" id="from" min="0" max="23" value="${f.from}"> bis
+      <input type="number" id="to" min="1" max="24" value="${f.to}"> Uhr`;
+  } else {
+    html += `<table class="days">`;
+    for (const [code, name] of weekdays) {
+      const d = f.days[code] || { on: false, from: 8, to: 12 };
+      html += `<tr><td><label><input type="checkbox" data-day="${code}" ${d.on ? "checked" : ""}> ${name}</label></td>
+        <td>von <input type="number" data-from="${code}" min="0" max="23" value="${d.from}">
+        bis <input type="number" data-to="${code}" min="1" max="24" value="${d.to}"> Uhr</td></tr>`;
+    }
+    html += `</table>`;
+  }
+  html += `<div><button data-back>Zurück</button><button class="primary" data-next>Weiter</button></div>`;
+  return html;
+}
+
+function calendar() {
+  const f = form;
+  const today = new Date();
+  today.setHours(0, 0, 0, 0);
+  if (!f.month) f.month = new Date(today.getFullYear(), today.getMonth(), 1);
+  const y = f.month.getFullYear(), mo = f.month.getMonth();
+  let html = `<table class="cal"><tr><th><a href="#" data-month="-1">‹</a></th>
+    <th colspan="5">${monthNames[mo]} ${y}</th><th><a href="#" data-month="1">›</a></th></tr>
+    <tr><th>Mo</th><th>Di</th><th>Mi</th><th>Do</th><th>Fr</th><th>Sa</th><th>So</th></tr><tr>`;
+  const offset = (new Date(y, mo, 1).getDay() + 6) % 7;
+  for (let i = 0; i < offset; i++) html += `<td></td>`;
+  const last = new Date(y, mo + 1, 0).getDate();
+  for (let d = 1; d <= last; d++) {
+    const day = new Date(y, mo, d);
+    const iso = `${y}-${pad(mo + 1)}-${pad(d)}`;
+    const cls = day < today ? "past" : "day" + (iso === f.date ? " sel" : "");
+    html += `<td class="${cls}" ${day < today ? "" : `data-date="${iso}"`}>${d}</td>`;
+    if ((offset + d) % 7 === 0 && d !== last) html += `</tr><tr>`;
+  }
+  return html + `</tr></table>`;
+}
+
+function reviewStep() {
+  const f = form;
+  let avail;
+  if (f.kind === "oneoff") {
+    avail = `${esc(f.date.split("-").reverse().join("."))}, ${pad(f.from)}–${pad(f.to)} Uhr`;
+  } else {
+    avail = weekdays.filter(([c]) => f.days[c] && f.days[c].on)
+      .map(([c, n]) => `${n} ${pad(f.days[c].from)}–${pad(f.days[c].to)} Uhr`).map(esc).join("<br>");
+  }
+  return `<h2>Prüfen</h2><dl>
+    <dt>Name</dt><dd>${esc(f.name)}</dd>
+    <dt>E-Mail</dt><dd>${esc(f.email)}</dd>
+    <dt>Telefon</dt><dd>${esc(f.phone)}</dd>
+    <dt>Leistung</dt><dd>${esc(f.path.join(" › "))}</dd>
+    ${f.fields.filter(fd => f.extra[fd.label]).map(fd => `<dt>${esc(fd.label)}</dt><dd>${esc(f.extra[fd.label])}</dd>`).join("")}
+    <dt>Verfügbarkeit</dt><dd>${avail}</dd></dl>
+    <div><button data-back>Zurück</button><button class="primary" data-submit>Terminsuche starten</button></div>`;
+}
+
+function readInputs() {
+  const f = form;
+  const v = id => (document.getElementById(id) || {}).value;
+  if (f.step === 0) {
+    f.name = v("name").trim();
+    f.email = v("email").trim();
+    f.phone = v("phone").trim();
+  }
+  if (f.step === 2) {
+    f.fields.forEach((fd, i) => {
+      const el = app.querySelector(`[data-field="${i}"]`);
+      f.extra[fd.label] = fd.type === "checkbox" ? (el.checked ? "ja" : "nein") : el.value.trim();
+    });
+  }
+  if (f.step === 3 && f.kind === "oneoff") {
+    f.from = parseInt(v("from"), 10);
+    f.to = parseInt(v("to"), 10);
+  }
+  if (f.step === 3 && f.kind === "recurring") {
+    for (const [code] of weekdays) {
+      f.days[code] = {
+        on: app.querySelector(`[data-day="${code}"]`).checked,
+        from: parseInt(app.querySelector(`[data-from="${code}"]`).value, 10),
+        to: parseInt(app.querySelector(`[data-to="${code}"]`).value, 10),
+      };
+    }
+  }
+}
+
+function hoursError(from, to) {
+  if (!(from >= 0 && from <= 23)) return "Von außerhalb von 0–23 Uhr";
+  if (!(to >= 1 && to <= 24)) return "Bis außerhalb von 1–24 Uhr";
+  if (to <= from) return "Bis muss nach Von liegen";
+  return "";
+}
+
+function validateStep() {
+  const f = form;
+  if (f.step === 0) {
+    if (!f.name || !f.email || !f.phone) return "Bitte Name, E-Mail und Telefon angeben.";
+    if (!f.email.includes("@")) return "Ungültige E-Mail-Adresse.";
+  }
+  if (f.step === 2) {
+    for (const fd of f.fields) {
+      const v = f.extra[fd.label] || "";
+      if (!v && !fd.optional) return `${fd.label}: Pflichtfeld`;
+      if (v && fd.pattern && !new RegExp(`^(?:${fd.pattern})$`).test(v)) {
+        return `${fd.label}: ungültiges Format` + (fd.hint ? ` (${fd.hint})` : "");
+      }
+    }
+  }
+  if (f.step === 3 && f.kind === "oneoff") {
+    if (!f.date) return "Bitte ein Datum im Kalender wählen.";
+    return hoursError(f.from, f.to);
+  }
+  if (f.step === 3 && f.kind === "recurring") {
+    const on = weekdays.filter(([c]) => f.days[c].on);
+    if (on.length === 0) return "Bitte mindestens einen Tag auswählen.";
+    for (const [c, n] of on) {
+      const err = hoursError(f.days[c].from, f.days[c].to);
+      if (err) return `${n}: ${err}`;
+    }
+  }
+  return "";
+}
+
+function buildRequest() {
+  const f = form;
+  const req = {
+    name: f.name, email: f.email, phone: f.phone,
+    extra: Object.fromEntries(f.fields.filter(fd => f.extra[fd.label]).map(fd => [fd.label, f.extra[fd.label]])),
+    menu: { path: f.path, selectors: f.selectors },
+    tz: tz,
+  };
+  if (f.kind === "oneoff") {
+    req.availability = { kind: "oneoff", oneoff: { date: f.date, from_hour: f.from, to_hour: f.to } };
+  } else {
+    req.availability = {
+      kind: "recurring",
+      recurring: {
+        days: weekdays.filter(([c]) => f.days[c].on)
+          .map(([c]) => ({ weekday: c, from_hour: f.days[c].from, to_hour: f.days[c].to })),
+      },
+    };
+  }
+  return req;
+}
+
+function bindForm() {
+  const f = form;
+  const on = (sel, fn) => app.querySelectorAll(sel).forEach(el => el.addEventListener("click", fn));
+
+  on("[data-next]", () => {
+    readInputs();
+    f.error = validateStep();
+    if (!f.error) f.step++;
+    if (f.step === 2 && f.fields.length === 0) f.step++;
+    renderForm();
+  });
+  on("[data-back]", () => {
+    readInputs();
+    f.error = "";
+    if (f.step === 1 && f.stack.length > 0) {
+      f.stack.pop();
+    } else if (f.step > 0) {
+      f.step--;
+      if (f.step === 2 && f.fields.length === 0) f.step--;
+    } else {
+      location.hash = "#/";
+      return;
+    }
+    renderForm();
+  });
+  on("[data-menu]", e => {
+    const i = parseInt(e.currentTarget.dataset.menu, 10);
+    const child = currentNode().children[i];
+    f.stack.push(i);
+    if (child.children && child.children.length > 0) {
+      renderForm();
+      return;
+    }
+    // Leaf reached: collect titles and selectors along the path like the TUI.
+    f.path = [];
+    f.selectors = [];
+    let node = menu;
+    for (const j of f.stack) {
+      node = node.children[j];
+      f.path.push(node.title);
+      if (node.selector) f.selectors.push(node.selector);
+    }
+    f.fields = node.fields || [];
+    f.extra = {};
+    f.stack.pop();
+    f.step = f.fields.length > 0 ? 2 : 3;
+    renderForm();
+  });
+  app.querySelectorAll("input[name=kind]").forEach(el => el.addEventListener("change", () => {
+    readInputs();
+    f.kind = el.value;
+    f.error = "";
+    renderForm();
+  }));
+  on("[data-month]", e => {
+    e.preventDefault();
+    readInputs();
+    f.month = new Date(f.month.getFullYear(), f.month.getMonth() + parseInt(e.currentTarget.dataset.month, 10), 1);
+    renderForm();
+  });
+  on("[data-date]", e => {
+    readInputs();
+    f.date = e.currentTarget.dataset.date;
+    renderForm();
+  });
+  on("[data-submit]", async e => {
+    e.currentTarget.disabled = true;
+    try {
+      const w = await api("POST", "/api/watches", buildRequest());
+      form = null;
+      location.hash = "#/watch/" + encodeURIComponent(w.id);
+    } catch (err) {
+      f.error = err.message;
+      renderForm();
+    }
+  });
+}
+
+function stateLine(w) {
+  const s = w.status;
+  switch (s.state) {
+    case "starting": return "startet…";
+    case "navigating": return "navigiert durchs Menü…";
+    case "listing": return "liest freie Termine…";
+    case "picking": return "wartet auf Auswahl…";
+    case "booking": return "bucht Termin…";
+    case "awaiting_code": return "wartet auf Bestätigungscode bis " + fmtTime(s.code_deadline);
+    case "sleeping": return "wartet bis " + fmtTime(s.next_poll);
+    case "paused": return w.paused ? "pausiert" : "pausiert bis " + fmtTime(s.next_poll);
+    case "booked": return "✅ Termin gebucht";
+    case "stopped": return "beendet";
+  }
+  return s.state;
+}
+
+function resultLine(w) {
+  switch (w.result) {
+    case "running": return "läuft";
+    case "confirmed": return "bestätigt, Code fehlt";
+    case "booked": return "gebucht";
+    case "failed": return "fehlgeschlagen";
+    case "cancelled": return "abgebrochen";
+  }
+  return w.result;
+}
+
+async function showWatch(id) {
+  let finished = false;
+  const render = async () => {
+    let w;
+    try {
+      w = await api("GET", "/api/watches/" + encodeURIComponent(id));
+    } catch (e) {
+      finished = true;
+      clearInterval(timer);
+      app.innerHTML = `<p class="error">${esc(e.message)}</p><a href="#/">Zur Übersicht</a>`;
+      return;
+    }
+    const s = w.status;
+    let html = `<h2>👀 Terminsuche #${esc(w.id)}</h2><dl>
+      <dt>Leistung</dt><dd>${esc((w.menu || []).join(" › "))}</dd>
+      <dt>Status</dt><dd>${esc(stateLine(w))}</dd>
+      <dt>Abfrage</dt><dd>#${s.poll || 0}${s.last_poll ? " · zuletzt erfolgreich " + esc(fmtTime(s.last_poll)) : ""}</dd>
+      <dt>Ergebnis</dt><dd>${esc(resultLine(w))}${w.error ? " – " + esc(w.error) : ""}</dd></dl>`;
+    if (s.state === "awaiting_code") {
+      html += `<form class="code" data-code><label>📧 Bestätigungscode aus der E-Mail</label>
+        <input type="text" id="code" autocomplete="one-time-code" inputmode="numeric">
+        <button class="primary">Code senden</button></form>`;
+    }
+    const slots = s.slots || [];
+    html += `<h3>Freie Termine (${slots.length})</h3>`;
+    if (slots.length === 0) {
+      html += `<p>(keine)</p>`;
+    } else {
+      html += `<ul class="slots">` + slots.map(sl =>
+        `<li class="${sl.match ? "match" : "nomatch"}">${sl.match ? "✔" : "✘"} ${esc(slotText(sl))}</li>`).join("") + `</ul>`;
+    }
+    const errors = (s.errors || []).slice(-5);
+    if (errors.length > 0) {
+      html += `<h3>Fehler</h3><ul class="errors">` + errors.map(e =>
+        `<li>⚠️ ${esc(fmtTime(e.at))} ${esc(e.err)}</li>`).join("") + `</ul>`;
+    }
+    if (w.result === "running") {
+      html += `<button data-act="${w.paused ? "resume" : "pause"}">${w.paused ? "Fortsetzen" : "Pausieren"}</button>
+        <button data-act="poll">Jetzt abfragen</button>
+        <button data-act="cancel">Abbrechen</button>`;
+    } else {
+      finished = true;
+      clearInterval(timer);
+    }
+    html += ` <a href="#/">Zur Übersicht</a>`;
+    // Keep what was typed into the code field across the refresh.
+    const old = document.getElementById("code");
+    const typed = old ? old.value : "", focused = old && document.activeElement === old;
+    app.innerHTML = html;
+    const codeInput = document.getElementById("code");
+    if (codeInput) {
+      codeInput.value = typed;
+      if (focused) codeInput.focus();
+    }
+    app.querySelectorAll("[data-code]").forEach(el => el.addEventListener("submit", async e => {
+      e.preventDefault();
+      try {
+        await api("POST", `/api/watches/${encodeURIComponent(id)}/code`, { code: codeInput.value.trim() });
+      } catch (err) {
+        alert(err.message);
+      }
+      render();
+    }));
+    app.querySelectorAll("[data-act]").forEach(el => el.addEventListener("click", async () => {
+      const act = el.dataset.act;
+      if (act === "cancel" && !confirm("Terminsuche wirklich abbrechen?")) return;
+      try {
+        if (act === "cancel") await api("DELETE", "/api/watches/" + encodeURIComponent(id));
+        else await api("POST", `/api/watches/${encodeURIComponent(id)}/${act}`);
+      } catch (e) {
+        alert(e.message);
+      }
+      render();
+    }));
+  };
+  await render();
+  if (!finished) timer = setInterval(render, 2000);
+}
+
+window.addEventListener("hashchange", route);
+route();
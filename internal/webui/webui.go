@@ -0,0 +1,20 @@
+// Package webui is a browser front end for the control API. It mirrors the
+// TUI: person data, service, availability, review and then the live status
+// of the watch.
+package webui
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var static embed.FS
+
+// Register serves the UI on /. The control API must be registered on the
+// same mux.
+func Register(mux *http.ServeMux) {
+	sub, _ := fs.Sub(static, "static")
+	mux.Handle("GET /", http.FileServerFS(sub))
+}